@@ -0,0 +1,720 @@
+package main
+
+import (
+	"crypto/x509"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"tnd/pkg/encoding/strictxml"
+	"tnd/work/csvToXmlParser/rdefiling"
+)
+
+var commands = []*command{
+	{
+		Name:  "spec",
+		Args:  "[form]",
+		Short: "print spec of form in json format",
+		Long:  "Spec prints every used field of spec with its json key of frontend after name mapping.",
+		Setup: setupGenerate((*rdefiling.Spec).WriteJSON),
+	},
+	{
+		Name:  "xsd",
+		Args:  "[form]",
+		Short: "generate xsd of form",
+		Long:  "Xsd generates xml schema of RdForm from spec.\nds:Signature is allowed in RdForm or in SignatureElements of form, -bundleSignatureSchema writes xmldsig-core schema next to xsd\nso xsd can be compiled offline.",
+		Setup: setupXSD,
+	},
+	{
+		Name:  "java",
+		Args:  "[form]",
+		Short: "generate part of java parser of form",
+		Long:  "Java generates lines of java parser which map json key of frontend to xml element.",
+		Setup: setupGenerate((*rdefiling.Spec).JavaParser),
+	},
+	{
+		Name:  "testdata",
+		Args:  "[form]",
+		Short: "generate xml filled with random test data",
+		Long:  "Testdata generates RdForm xml whose every element is filled with random value of its type.\nUse convert to make xml from json of frontend.",
+		Setup: setupGenerate((*rdefiling.Spec).TestData),
+	},
+	{
+		Name:  "convert",
+		Args:  "[form] <input>",
+		Short: "convert json of frontend to RdForm xml or back",
+		Long:  "Convert makes RdForm xml from json of frontend when input is .json, and json of frontend from RdForm xml when input is .xml.\nTestData of form decide whether value is put under page1 or page2 when converting xml to json.",
+		Setup: setupConvert,
+	},
+	{
+		Name:  "validate",
+		Args:  "[form] <xml>...",
+		Short: "validate RdForm xml against spec and rule guideline",
+		Long:  "Validate checks structure and type of every element of RdForm xml against spec, then checks rule guideline of each field.",
+		Setup: setupValidate,
+	},
+	{
+		Name:  "sign",
+		Args:  "<xml>",
+		Short: "sign RdForm xml with enveloped XML-DSig",
+		Long:  "Sign puts ds:Signature at the end of root element of xml, signed by RSA-SHA256 over exclusive canonical form of whole document.\nKey is PKCS#12 file (.p12 or .pfx) or PEM private key with PEM certificate in -cert or in key file itself.",
+		Setup: setupSign,
+	},
+	{
+		Name:  "verify",
+		Args:  "<xml>...",
+		Short: "verify XML-DSig of signed RdForm xml",
+		Long:  "Verify checks digest and signature value of every ds:Signature of xml and prints certificate of each valid signature.\nSignature of RdForm must sign whole document with reference URI=\"\", reference by Id must match exactly one element.\nWithout -cert only integrity is checked with certificate in KeyInfo.",
+		Setup: setupVerify,
+	},
+	{
+		Name:  "coverage",
+		Args:  "[form]",
+		Short: "report which spec fields frontend json can supply",
+		Long:  "Coverage lists mapped, unmapped and NU (not used) fields of spec and xml name mapping entries matching no field.\nMapped fields are looked up in sample json, default to TestData of form, to show ToKey without value.",
+		Setup: setupCoverage,
+	},
+	{
+		Name:  "suggest-mapping",
+		Args:  "<spec> <sample.json>",
+		Short: "propose xml name mapping from sample json",
+		Long: "Suggest-mapping pairs each field of spec with a path of filled frontend json, scoring name after lowerFirst,\n" +
+			"position of parent objects, type of value and array shape. Spec is a form, a spec in csv or xlsx format, or spec json.\n" +
+			"Json output lists every suggestion with its scores for review, mapping output is a draft xmlNameMapping.json\n" +
+			"without fields whose ToKey follows from their parent.",
+		Setup: setupSuggestMapping,
+	},
+	{
+		Name:  "format",
+		Args:  "<xml>...",
+		Short: "indent xml file",
+		Long:  "Format puts each element of element-only content on its own line, text and mixed content are kept as written.\nFile is streamed, so large batch xml can be formatted too. Output replaces file only after whole file is formatted.",
+		Setup: setupFormat,
+	},
+	{
+		Name:  "diff",
+		Args:  "<old> <new>",
+		Short: "compare fields of two spec versions",
+		Long:  "Diff lists fields added, removed or changed between two specs, matched by xml path.\nEach argument is a form, a spec in csv or xlsx format, or spec json printed by spec command.",
+		Setup: setupDiff,
+	},
+	{
+		Name:  "build-all",
+		Args:  "[root]",
+		Short: "build every form below root directory",
+		Long:  "Build-all finds every form directory below root (default specFile), a directory with form.json or xmlNameMapping.json,\nand writes spec json, xsd, java parser and sample xml of each form into its own folder of output directory.\nForm which fails does not stop the others.",
+		Setup: setupBuildAll,
+	},
+}
+
+// isForm report whether argument is form directory or project file.
+func isForm(path string) bool {
+	if filepath.Base(path) == rdefiling.FormFile {
+		return true
+	}
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
+
+// parseFormArgs split form argument from the rest, form may be omitted when -spec is given.
+func parseFormArgs(args []string, form *formFlags) ([]string, []string, error) {
+	if len(args) > 0 && isForm(args[0]) {
+		return args[:1], args[1:], nil
+	}
+	if form.spec == "" {
+		return nil, nil, errUsage
+	}
+	return nil, args, nil
+}
+
+func setupGenerate(generate func(*rdefiling.Spec, io.Writer) error) func(flags *flag.FlagSet) func(args []string) error {
+	return func(flags *flag.FlagSet) func(args []string) error {
+		var form formFlags
+		form.register(flags)
+		output := flags.String("o", "", "output file, default to stdout")
+		return func(args []string) error {
+			formArgs, args, err := parseFormArgs(args, &form)
+			if err != nil {
+				return err
+			}
+			if len(args) != 0 {
+				return errUsage
+			}
+			_, spec, err := form.load(formArgs)
+			if err != nil {
+				return err
+			}
+			return writeOutput(*output, func(writer io.Writer) error {
+				return generate(spec, writer)
+			})
+		}
+	}
+}
+
+// writeSignatureSchema write bundled xmldsig-core schema into dir and return its schemaLocation relative to xsd.
+func writeSignatureSchema(dir string) (string, error) {
+	err := writeOutput(filepath.Join(dir, rdefiling.XMLDSigSchemaFile), func(writer io.Writer) error {
+		_, err := io.WriteString(writer, rdefiling.XMLDSigSchema)
+		return err
+	})
+	return rdefiling.XMLDSigSchemaFile, err
+}
+
+func setupXSD(flags *flag.FlagSet) func(args []string) error {
+	var formFlag formFlags
+	formFlag.register(flags)
+	output := flags.String("o", "", "output file, default to stdout")
+	annotate := flags.Bool("annotate", true, "put description, Index and rule guideline codes into xs:annotation, -annotate=false for lean schema")
+	bundle := flags.Bool("bundleSignatureSchema", false, "write "+rdefiling.XMLDSigSchemaFile+" next to output and import it by schemaLocation, need -o")
+	return func(args []string) error {
+		formArgs, args, err := parseFormArgs(args, &formFlag)
+		if err != nil {
+			return err
+		}
+		if len(args) != 0 || (*bundle && (*output == "" || *output == "-")) {
+			return errUsage
+		}
+		form, spec, err := formFlag.load(formArgs)
+		if err != nil {
+			return err
+		}
+		options := rdefiling.XSDOptions{Annotate: *annotate, SignatureElements: form.SignatureElements}
+		if *bundle {
+			if options.SignatureSchemaLocation, err = writeSignatureSchema(filepath.Dir(*output)); err != nil {
+				return err
+			}
+		}
+		return writeOutput(*output, func(writer io.Writer) error {
+			return spec.WriteXSD(writer, options)
+		})
+	}
+}
+
+func setupConvert(flags *flag.FlagSet) func(args []string) error {
+	var formFlag formFlags
+	formFlag.register(flags)
+	output := flags.String("o", "", "output file, default to stdout")
+	template := flags.String("template", "", "json file of frontend decide page of value when converting xml to json, default to TestData of form")
+	return func(args []string) error {
+		formArgs, args, err := parseFormArgs(args, &formFlag)
+		if err != nil {
+			return err
+		}
+		if len(args) != 1 {
+			return errUsage
+		}
+		input := args[0]
+		form, spec, err := formFlag.load(formArgs)
+		if err != nil {
+			return err
+		}
+		inputFile, err := os.Open(input)
+		if err != nil {
+			return err
+		}
+		defer inputFile.Close()
+		if hasExt(input, ".json") {
+			return writeOutput(*output, func(writer io.Writer) error {
+				return spec.JSONToXML(inputFile, input, writer)
+			})
+		}
+		templateName := *template
+		if templateName == "" {
+			templateName = form.Path(form.TestData)
+		}
+		var templateReader io.Reader
+		if templateName != "" {
+			templateFile, err := os.Open(templateName)
+			if err != nil {
+				return err
+			}
+			defer templateFile.Close()
+			templateReader = templateFile
+		}
+		return writeOutput(*output, func(writer io.Writer) error {
+			return spec.XMLToJSON(inputFile, input, templateReader, templateName, writer)
+		})
+	}
+}
+
+func setupValidate(flags *flag.FlagSet) func(args []string) error {
+	var formFlag formFlags
+	formFlag.register(flags)
+	ruleCatalogue := flags.String("ruleCatalogue", "", "json file define rule guideline checks, default to RuleCatalogue of form or built-in rules")
+	return func(args []string) error {
+		formArgs, args, err := parseFormArgs(args, &formFlag)
+		if err != nil {
+			return err
+		}
+		if len(args) == 0 {
+			return errUsage
+		}
+		form, spec, err := formFlag.load(formArgs)
+		if err != nil {
+			return err
+		}
+		catalogue := rdefiling.BuiltInRuleCatalogue()
+		catalogueName := *ruleCatalogue
+		if catalogueName == "" {
+			catalogueName = form.Path(form.RuleCatalogue)
+		}
+		if catalogueName != "" {
+			file, err := os.Open(catalogueName)
+			if err != nil {
+				return err
+			}
+			defer file.Close()
+			catalogue, err = rdefiling.ReadRuleCatalogue(file, catalogueName)
+			if err != nil {
+				return err
+			}
+		}
+		for _, name := range args {
+			file, err := os.Open(name)
+			if err != nil {
+				addError(name, err)
+				continue
+			}
+			result, err := spec.Validate(file, name, catalogue, form.SignatureElements)
+			file.Close()
+			diagnostics = append(diagnostics, result...)
+			if err != nil {
+				addError(name, err)
+			}
+		}
+		return nil
+	}
+}
+
+func setupSign(flags *flag.FlagSet) func(args []string) error {
+	key := flags.String("key", "", "private key in PEM format, or PKCS#12 file with extension .p12 or .pfx")
+	cert := flags.String("cert", "", "certificate of key in PEM format, followed by its chain, not needed when key file contains certificate")
+	password := flags.String("password", "", "password of PKCS#12 file or encrypted key")
+	passwordEnv := flags.String("passwordEnv", "", "environment variable holding password, keep password out of process list")
+	output := flags.String("o", "", "output file, default to stdout")
+	return func(args []string) error {
+		if len(args) != 1 || *key == "" {
+			return errUsage
+		}
+		if *passwordEnv != "" {
+			*password = os.Getenv(*passwordEnv)
+		}
+		signingKey, err := rdefiling.ReadSigningKey(*key, *cert, *password)
+		if err != nil {
+			return err
+		}
+		file, err := os.Open(args[0])
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		return writeOutput(*output, func(writer io.Writer) error {
+			return rdefiling.SignXML(file, args[0], writer, signingKey)
+		})
+	}
+}
+
+func setupVerify(flags *flag.FlagSet) func(args []string) error {
+	cert := flags.String("cert", "", "trusted certificates in PEM format, signer certificate or its issuer")
+	return func(args []string) error {
+		if len(args) == 0 {
+			return errUsage
+		}
+		var trusted []*x509.Certificate
+		if *cert != "" {
+			var err error
+			if trusted, err = rdefiling.ReadCertificates(*cert); err != nil {
+				return err
+			}
+		}
+		for _, name := range args {
+			file, err := os.Open(name)
+			if err != nil {
+				addError(name, err)
+				continue
+			}
+			signers, result, err := rdefiling.VerifyXML(file, name, trusted)
+			file.Close()
+			diagnostics = append(diagnostics, result...)
+			if err != nil {
+				addError(name, err)
+			}
+			for _, signer := range signers {
+				fmt.Printf("%s: signed by %s, serial %s, valid until %s\n", name, signer.Subject, signer.SerialNumber, signer.NotAfter.Format("2006-01-02"))
+			}
+		}
+		return nil
+	}
+}
+
+func setupCoverage(flags *flag.FlagSet) func(args []string) error {
+	var formFlag formFlags
+	formFlag.register(flags)
+	format := flags.String("format", "table", "output format, table, csv or json")
+	sample := flags.String("sample", "", "json file of frontend to look up mapped fields, default to TestData of form")
+	output := flags.String("o", "", "output file, default to stdout")
+	return func(args []string) error {
+		formArgs, args, err := parseFormArgs(args, &formFlag)
+		if err != nil {
+			return err
+		}
+		if len(args) != 0 || *format != "table" && *format != "csv" && *format != "json" {
+			return errUsage
+		}
+		form, spec, err := formFlag.load(formArgs)
+		if err != nil {
+			return err
+		}
+		sampleName := *sample
+		if sampleName == "" {
+			sampleName = form.Path(form.TestData)
+		}
+		var sampleReader io.Reader
+		if sampleName != "" {
+			sampleFile, err := os.Open(sampleName)
+			if err != nil {
+				return err
+			}
+			defer sampleFile.Close()
+			sampleReader = sampleFile
+		}
+		coverage, err := spec.Coverage(sampleReader, sampleName)
+		if err != nil {
+			return err
+		}
+		return writeOutput(*output, func(writer io.Writer) error {
+			switch *format {
+			case "csv":
+				return writeCoverageCSV(writer, coverage)
+			case "json":
+				encoder := json.NewEncoder(writer)
+				encoder.SetIndent("", "    ")
+				return encoder.Encode(coverage)
+			}
+			return writeCoverageTable(writer, form.Name, coverage)
+		})
+	}
+}
+
+// sampleColumn show whether mapped field has value in sample json.
+func sampleColumn(field rdefiling.FieldCoverage) string {
+	switch {
+	case field.InSample == nil:
+		return ""
+	case *field.InSample:
+		return "yes"
+	}
+	return "missing"
+}
+
+func writeCoverageTable(writer io.Writer, name string, coverage *rdefiling.Coverage) error {
+	table := tabwriter.NewWriter(writer, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(table, "STATUS\tINDEX\tFROMKEY\tTOKEY\tSAMPLE")
+	for _, field := range coverage.Fields {
+		fmt.Fprintf(table, "%s\t%s\t%s\t%s\t%s\n", field.Status, field.Index, field.FromKey, field.ToKey, sampleColumn(field))
+	}
+	for _, prefix := range coverage.UnusedMappings {
+		fmt.Fprintf(table, "unused mapping\t\t%s\t\t\n", prefix)
+	}
+	if err := table.Flush(); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(writer, "%s: %d mapped, %d unmapped, %d NU field(s), %d mapped field(s) missing in sample, %d unused mapping(s)\n",
+		name, coverage.Mapped, coverage.Unmapped, coverage.NotUsed, coverage.MissingInSample, len(coverage.UnusedMappings))
+	return err
+}
+
+func writeCoverageCSV(writer io.Writer, coverage *rdefiling.Coverage) error {
+	csvWriter := csv.NewWriter(writer)
+	csvWriter.Write([]string{"Status", "Index", "FromKey", "ToKey", "Type", "Sample"})
+	for _, field := range coverage.Fields {
+		csvWriter.Write([]string{field.Status, field.Index, field.FromKey, field.ToKey, field.Type, sampleColumn(field)})
+	}
+	for _, prefix := range coverage.UnusedMappings {
+		csvWriter.Write([]string{"unused mapping", "", prefix, "", "", ""})
+	}
+	csvWriter.Flush()
+	return csvWriter.Error()
+}
+
+func setupSuggestMapping(flags *flag.FlagSet) func(args []string) error {
+	format := flags.String("format", "json", "output format, json, table or mapping")
+	minConfidence := flags.Float64("min", 0.6, "minimum confidence of suggestion, between 0 and 1")
+	output := flags.String("o", "", "output file, default to stdout")
+	return func(args []string) error {
+		if len(args) != 2 || *format != "json" && *format != "table" && *format != "mapping" {
+			return errUsage
+		}
+		spec, err := loadSpecArg(args[0])
+		if spec != nil {
+			diagnostics = append(diagnostics, spec.Diagnostics...)
+		}
+		if err != nil {
+			return err
+		}
+		sample, err := os.Open(args[1])
+		if err != nil {
+			return err
+		}
+		defer sample.Close()
+		suggestions, err := spec.SuggestMapping(sample, args[1], *minConfidence)
+		if err != nil {
+			return err
+		}
+		return writeOutput(*output, func(writer io.Writer) error {
+			switch *format {
+			case "table":
+				return writeSuggestionTable(writer, len(spec.Fields), suggestions)
+			case "mapping":
+				encoder := json.NewEncoder(writer)
+				encoder.SetEscapeHTML(false)
+				encoder.SetIndent("", "\t")
+				return encoder.Encode(rdefiling.DraftMapping(suggestions))
+			}
+			encoder := json.NewEncoder(writer)
+			encoder.SetIndent("", "    ")
+			if suggestions == nil {
+				suggestions = []rdefiling.MappingSuggestion{}
+			}
+			return encoder.Encode(suggestions)
+		})
+	}
+}
+
+func writeSuggestionTable(writer io.Writer, fieldCount int, suggestions []rdefiling.MappingSuggestion) error {
+	table := tabwriter.NewWriter(writer, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(table, "CONFIDENCE\tINDEX\tFROMKEY\tTOKEY\tTYPE\tJSON")
+	for _, suggestion := range suggestions {
+		fmt.Fprintf(table, "%.2f\t%s\t%s\t%s\t%s\t%s\n", suggestion.Confidence, suggestion.Index, suggestion.FromKey,
+			suggestion.ToKey, suggestion.Type, suggestion.JSONType)
+	}
+	if err := table.Flush(); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(writer, "%d of %d field(s) suggested\n", len(suggestions), fieldCount)
+	return err
+}
+
+func setupFormat(flags *flag.FlagSet) func(args []string) error {
+	indent := flags.String("indent", "\t", "indent of each level")
+	write := flags.Bool("w", false, "write result to file itself instead of stdout")
+	output := flags.String("o", "", "output file, default to stdout")
+	return func(args []string) error {
+		if len(args) == 0 || *write && *output != "" || *output != "" && len(args) > 1 {
+			return errUsage
+		}
+		for _, name := range args {
+			path := *output
+			if *write {
+				path = name
+			}
+			if err := formatXML(name, path, *indent); err != nil {
+				addError(name, err)
+			}
+		}
+		return nil
+	}
+}
+
+// formatXML indent xml file name into path, path may be name itself.
+func formatXML(name, path, indent string) error {
+	file, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return writeOutput(path, func(writer io.Writer) error {
+		err := strictxml.FormatIndent(file, writer, "", indent)
+		if syntaxError, ok := err.(*xml.SyntaxError); ok {
+			return &rdefiling.Diagnostic{Severity: rdefiling.SeverityError, Stage: "format", File: name, Line: syntaxError.Line, Message: syntaxError.Msg}
+		}
+		return err
+	})
+}
+
+// loadSpecArg read form, spec file or spec json, mapping is applied only to form.
+func loadSpecArg(path string) (*rdefiling.Spec, error) {
+	if isForm(path) {
+		form, err := rdefiling.ReadForm(path)
+		if err != nil {
+			return nil, err
+		}
+		return form.Load()
+	}
+	if hasExt(path, ".json") {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		return rdefiling.ReadSpecJSON(file, path)
+	}
+	return rdefiling.ReadSpec(path, rdefiling.SpecOptions{})
+}
+
+func writeDiffText(writer io.Writer, changes []rdefiling.FieldChange) error {
+	var lines []string
+	for _, change := range changes {
+		switch change.Change {
+		case "added":
+			lines = append(lines, fmt.Sprintf("+ %s %s (%s)", change.New.Index, change.FromKey, change.New.Type))
+		case "removed":
+			lines = append(lines, fmt.Sprintf("- %s %s (%s)", change.Old.Index, change.FromKey, change.Old.Type))
+		default:
+			lines = append(lines, fmt.Sprintf("~ %s %s", change.New.Index, change.FromKey))
+			for _, attribute := range change.Attributes {
+				lines = append(lines, fmt.Sprintf("    %s: %q -> %q", attribute, rdefiling.DiffValue(change.Old, attribute), rdefiling.DiffValue(change.New, attribute)))
+			}
+		}
+	}
+	lines = append(lines, fmt.Sprintf("%d field(s) changed", len(changes)))
+	_, err := io.WriteString(writer, strings.Join(lines, "\n")+"\n")
+	return err
+}
+
+func setupDiff(flags *flag.FlagSet) func(args []string) error {
+	format := flags.String("format", "text", "output format, text or json")
+	output := flags.String("o", "", "output file, default to stdout")
+	return func(args []string) error {
+		if len(args) != 2 {
+			return errUsage
+		}
+		var specs []*rdefiling.Spec
+		for _, path := range args {
+			spec, err := loadSpecArg(path)
+			if spec != nil {
+				diagnostics = append(diagnostics, spec.Diagnostics...)
+			}
+			if err != nil {
+				return err
+			}
+			specs = append(specs, spec)
+		}
+		changes := rdefiling.DiffSpecs(specs[0], specs[1])
+		return writeOutput(*output, func(writer io.Writer) error {
+			if *format != "json" {
+				return writeDiffText(writer, changes)
+			}
+			encoder := json.NewEncoder(writer)
+			encoder.SetIndent("", "    ")
+			if changes == nil {
+				changes = []rdefiling.FieldChange{}
+			}
+			return encoder.Encode(changes)
+		})
+	}
+}
+
+type buildResult struct {
+	Form        string
+	Output      string
+	Diagnostics rdefiling.Diagnostics
+}
+
+// buildForm run full pipeline of form in dir, sample xml is converted from TestData of form or filled with random data.
+func buildForm(dir string, outputRoot string, xsdOptions rdefiling.XSDOptions, bundleSignatureSchema bool) buildResult {
+	result := buildResult{Form: dir}
+	form, err := rdefiling.ReadForm(dir)
+	if err != nil {
+		result.Diagnostics = append(result.Diagnostics, asDiagnostic(dir, err))
+		return result
+	}
+	result.Form = form.Name
+	spec, err := form.Load()
+	if err != nil {
+		if spec != nil {
+			result.Diagnostics = append(result.Diagnostics, spec.Diagnostics...)
+		}
+		result.Diagnostics = append(result.Diagnostics, asDiagnostic(form.Path(form.Spec), err))
+		return result
+	}
+	result.Output = filepath.Join(outputRoot, form.Name)
+	var outputErrors rdefiling.Diagnostics
+	xsdOptions.SignatureElements = form.SignatureElements
+	if bundleSignatureSchema {
+		if xsdOptions.SignatureSchemaLocation, err = writeSignatureSchema(result.Output); err != nil {
+			outputErrors = append(outputErrors, asDiagnostic(filepath.Join(result.Output, rdefiling.XMLDSigSchemaFile), err))
+		}
+	}
+	sample := spec.TestData
+	if form.TestData != "" {
+		sample = func(writer io.Writer) error {
+			file, err := os.Open(form.Path(form.TestData))
+			if err != nil {
+				return err
+			}
+			defer file.Close()
+			return spec.JSONToXML(file, form.Path(form.TestData), writer)
+		}
+	}
+	outputs := []struct {
+		File  string
+		Write func(io.Writer) error
+	}{
+		{"spec.json", spec.WriteJSON},
+		{form.Name + ".xsd", func(writer io.Writer) error {
+			return spec.WriteXSD(writer, xsdOptions)
+		}},
+		{"parser.java", spec.JavaParser},
+		{"testData.xml", sample},
+	}
+	for _, output := range outputs {
+		path := filepath.Join(result.Output, output.File)
+		if err := writeOutput(path, output.Write); err != nil {
+			outputErrors = append(outputErrors, asDiagnostic(path, err))
+		}
+	}
+	result.Diagnostics = append(append(result.Diagnostics, spec.Diagnostics...), outputErrors...)
+	return result
+}
+
+func setupBuildAll(flags *flag.FlagSet) func(args []string) error {
+	output := flags.String("o", "output", "output directory, each form is written into folder of its name")
+	annotate := flags.Bool("annotate", true, "put description, Index and rule guideline codes into xs:annotation of xsd")
+	bundle := flags.Bool("bundleSignatureSchema", false, "write "+rdefiling.XMLDSigSchemaFile+" next to xsd of each form and import it by schemaLocation")
+	return func(args []string) error {
+		root := "specFile"
+		switch len(args) {
+		case 0:
+		case 1:
+			root = args[0]
+		default:
+			return errUsage
+		}
+		dirs, err := rdefiling.FindForms(root)
+		if err != nil {
+			return err
+		}
+		if len(dirs) == 0 {
+			return &rdefiling.Diagnostic{Severity: rdefiling.SeverityError, Stage: "form", File: root, Message: "no form found"}
+		}
+		table := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(table, "FORM\tSTATUS\tERRORS\tWARNINGS\tOUTPUT")
+		failed := 0
+		for _, dir := range dirs {
+			result := buildForm(dir, *output, rdefiling.XSDOptions{Annotate: *annotate}, *bundle)
+			diagnostics = append(diagnostics, result.Diagnostics...)
+			errorCount := 0
+			for _, d := range result.Diagnostics {
+				if d.Severity == rdefiling.SeverityError {
+					errorCount++
+				}
+			}
+			status := "ok"
+			if errorCount > 0 {
+				status = "failed"
+				failed++
+			}
+			fmt.Fprintf(table, "%s\t%s\t%d\t%d\t%s\n", result.Form, status, errorCount, len(result.Diagnostics)-errorCount, result.Output)
+		}
+		table.Flush()
+		fmt.Printf("%d form(s), %d failed\n", len(dirs), failed)
+		return nil
+	}
+}
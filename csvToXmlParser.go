@@ -1,481 +1,274 @@
 package main
 
 import (
-	"bytes"
-	"encoding/csv"
-	"encoding/json"
-	"encoding/xml"
 	"flag"
+	"fmt"
 	"io"
 	"io/ioutil"
-	"log"
 	"os"
-	"sort"
+	"path/filepath"
 	"strings"
-	"tnd/pkg/encoding/strictxml"
+	"tnd/work/csvToXmlParser/rdefiling"
 )
 
-type FromToKey struct {
-	From string
-	To   string
-}
-
-var forceArrayType = map[string]bool{}
-var specialName map[string]string
-var fromToKeyMap []FromToKey
-
 // const inputFile = `C:\TND_DATA\workData\2019-01-29\pnd52\PND52_XML_Public_2562_05062020.csv`
 
 // const inputFile = `C:\TND_DATA\workData\2019-01-29\pnd55\XML Public Pnd55.csv`
 
-const xmlNameSpace = "urn:schemas-rd-go-th:xml-services:common"
-
-var specFile = flag.String("spec", ``, `xml spec file in csv format`)
-var specContextLength = flag.Int("specContextLength", 8, "length of xml hierarchy")
-var tempJSONFile = flag.String("printJSONSpec", ``, "file to print spec in json format")
-var javaParserFile = flag.String("javaParser", ``, "print part of java parser into file")
-var jsonTestDataFile = flag.String("jsonTestData", ``, "json file to copy test data from")
-var xmlTestDataFile = flag.String("xmlTestData", ``, "xml file to contain test data")
-var xmlNameSubstitutionFileName = flag.String("nameSubstitution", ``, `json file represent name substitution`)
-var xmlNameMapping = flag.String("xmlNameMapping", ``, `json file represent prefix name mapping`)
-var xsdFile = flag.String("xsdFile", ``, `filepath to output generated xsd file`)
+type command struct {
+	Name  string
+	Args  string
+	Short string
+	Long  string
+	Setup func(flags *flag.FlagSet) func(args []string) error // register flags and return run function
+}
 
-type fromToKeySorter []FromToKey
+var diagnostics rdefiling.Diagnostics
+var loadedSpecs []*rdefiling.Spec // diagnostics of spec are collected after command ran, generators add theirs too
+var diagnosticsFormat string
 
-func (ftk fromToKeySorter) Len() int {
-	return len(ftk)
-}
-func (ftk fromToKeySorter) Swap(a, b int) {
-	ftk[a], ftk[b] = ftk[b], ftk[a]
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s <command> [flags] [arguments]\n\nCommands:\n", filepath.Base(os.Args[0]))
+	for _, c := range commands {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", c.Name, c.Short)
+	}
+	fmt.Fprintf(os.Stderr, "\nForm is a directory containing %s or the project file itself. Flags may also follow arguments.\n", rdefiling.FormFile)
+	fmt.Fprintf(os.Stderr, "Run '%s help <command>' for flags of command.\n", filepath.Base(os.Args[0]))
 }
-func (ftk fromToKeySorter) Less(a, b int) bool {
-	return ftk[a].From < ftk[b].From
+
+func findCommand(name string) *command {
+	for _, c := range commands {
+		if c.Name == name {
+			return c
+		}
+	}
+	return nil
 }
 
-func readNameMappingFile() {
-	specialName = make(map[string]string)
-	file, err := os.Open(*xmlNameMapping)
-	if err != nil {
-		panic(err)
+// flagSet return flags of command and function to run command with arguments after flags.
+func (c *command) flagSet() (*flag.FlagSet, func(args []string) error) {
+	flags := flag.NewFlagSet(c.Name, flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s %s [flags] %s\n\n%s\n", filepath.Base(os.Args[0]), c.Name, c.Args, c.Long)
+		var hasFlag bool
+		flags.VisitAll(func(*flag.Flag) { hasFlag = true })
+		if hasFlag {
+			fmt.Fprintln(os.Stderr, "\nFlags:")
+			flags.PrintDefaults()
+		}
 	}
-	defer file.Close()
-	var nameMapping map[string]interface{}
-	err = json.NewDecoder(file).Decode(&nameMapping)
-	if err != nil {
-		panic(err)
+	flags.StringVar(&diagnosticsFormat, "diagnostics-format", "text", "format of diagnostics summary, text or json")
+	return flags, c.Setup(flags)
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
 	}
-	add := func(key, value string) {
-		fromToKeyMap = append(fromToKeyMap, FromToKey{From: key, To: value})
+	name, args := os.Args[1], os.Args[2:]
+	if name == "help" || name == "-h" || name == "-help" || name == "--help" {
+		if len(args) > 0 && findCommand(args[0]) != nil {
+			flags, _ := findCommand(args[0]).flagSet()
+			flags.Usage()
+			return
+		}
+		usage()
+		return
 	}
-	for key, v := range nameMapping {
-		add(key, v.(string))
+	c := findCommand(name)
+	if c == nil {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n", name)
+		usage()
+		os.Exit(2)
 	}
-	file, err = os.Open(*xmlNameSubstitutionFileName)
+	flags, run := c.flagSet()
+	args, err := parseFlags(flags, args)
 	if err != nil {
-		panic(err)
+		if err == flag.ErrHelp {
+			return
+		}
+		os.Exit(2)
 	}
-	defer file.Close()
-	err = json.NewDecoder(file).Decode(&nameMapping)
-	if err != nil {
-		panic(err)
+	if err := run(args); err != nil {
+		if err == errUsage {
+			flags.Usage()
+			os.Exit(2)
+		}
+		addError("", err)
 	}
-	add = func(key, value string) {
-		specialName[key] = value
+	for _, spec := range loadedSpecs {
+		diagnostics = append(diagnostics, spec.Diagnostics...)
 	}
-	for key, v := range nameMapping {
-		add(key, v.(string))
+	printDiagnostics()
+	if diagnostics.HasError() {
+		os.Exit(1)
 	}
-	sort.Sort(fromToKeySorter(fromToKeyMap))
 }
 
-type JsonOutput struct {
-	Index       string
-	FromKey     string
-	ToKey       string `json:",omitempty"`
-	Description string
-	Type        string
-	MaxLength   string
-	Multiple    string
-	Input       string
-}
+var errUsage = fmt.Errorf("wrong usage")
 
-type AnyXML struct {
-	XMLName xml.Name
-	Nodes   []AnyXML   `xml:",any"`
-	Attrs   []xml.Attr `xml:",attr,any"`
-	Data    string     `xml:",chardata"`
+// parseFlags parse flags before, between and after arguments, such as "xsd specFile/pnd50_2563 -o x.xsd".
+// Arguments after "--" are never flags.
+func parseFlags(flags *flag.FlagSet, args []string) ([]string, error) {
+	var arguments []string
+	for {
+		if err := flags.Parse(args); err != nil {
+			return nil, err
+		}
+		rest := flags.Args()
+		if len(rest) == 0 {
+			return arguments, nil
+		}
+		if len(rest) < len(args) && args[len(args)-len(rest)-1] == "--" {
+			return append(arguments, rest...), nil
+		}
+		arguments = append(arguments, rest[0])
+		args = rest[1:]
+	}
 }
 
-func checkFlag() bool {
-	if *specFile == "" {
-		log.Println("Need Xml Spec file")
-		return false
+func printDiagnostics() {
+	if diagnosticsFormat == "json" {
+		diagnostics.WriteJSON(os.Stderr)
+	} else {
+		diagnostics.WriteText(os.Stderr)
 	}
-	if *xmlNameMapping == "" {
-		log.Println("Need xml name mapping file")
-		return false
+}
+
+// asDiagnostic keep error returned from rdefiling, error which is not diagnostic is i/o error of file.
+func asDiagnostic(file string, err error) rdefiling.Diagnostic {
+	if d, ok := err.(*rdefiling.Diagnostic); ok {
+		return *d
 	}
-	if *xmlNameSubstitutionFileName == "" {
-		log.Println("Need xml name subtitution file")
-		return false
+	return rdefiling.Diagnostic{
+		Severity: rdefiling.SeverityError,
+		Stage:    "io",
+		File:     file,
+		Message:  err.Error(),
 	}
-	return true
 }
 
-func main() {
-	flag.Parse()
-	if !checkFlag() {
-		return
+func addError(file string, err error) {
+	diagnostics = append(diagnostics, asDiagnostic(file, err))
+}
+
+// writeOutput write to file or to stdout when path is empty or "-". File is written into temporary file
+// renamed over path only when write succeeds, so existing file is kept on error and path may be input of write too.
+func writeOutput(path string, write func(io.Writer) error) error {
+	if path == "" || path == "-" {
+		return write(os.Stdout)
 	}
-	excelCsvToJson()
-	readNameMappingFile()
-	modifyRule()
-	if *javaParserFile != "" {
-		createParser(*javaParserFile)
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
 	}
-	if *xsdFile != "" {
-		createXsd(*xsdFile)
-		prettyPrintXML(*xsdFile)
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
 	}
-	if *xmlTestDataFile != "" {
-		if *jsonTestDataFile != "" {
-			jsonToXML()
-		} else {
-			createTestData()
-		}
-	}
-}
-
-func createTestData() {
-	jsonInput := readJson()
-	outFile, err := os.Create(*xmlTestDataFile)
+	file, err := ioutil.TempFile(dir, "."+filepath.Base(path)+".*.tmp")
 	if err != nil {
-		panic(err)
+		return err
 	}
-	defer outFile.Close()
-
-	root := AnyXML{XMLName: xml.Name{Local: "rd:RdForm"}}
-	root.Attrs = append(root.Attrs, xml.Attr{Name: xml.Name{Local: "xmlns:rd"}, Value: xmlNameSpace})
-	var arrayNames []string
-	setXMLValue := func(name string, value string) {
-		tokens := strings.Split(name, ".")
-		current := &root
-	tokensLoop:
-		for _, n := range tokens {
-			for i := range current.Nodes {
-				if current.Nodes[i].XMLName.Local[3:] == n {
-					current = &current.Nodes[i]
-					continue tokensLoop
-				}
-			}
-			current.Nodes = append(current.Nodes, AnyXML{
-				XMLName: xml.Name{Local: "rd:" + n},
-			})
-			current = &current.Nodes[len(current.Nodes)-1]
-		}
-		current.Data = value
+	err = write(file)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
 	}
-	duplicateXMLTag := func(name string) {
-		tokens := strings.Split(name, ".")
-		var parent *AnyXML
-		current := &root
-		currentIdx := 0
-	tokensLoop:
-		for _, n := range tokens {
-			for i := range current.Nodes {
-				if current.Nodes[i].XMLName.Local[3:] == n {
-					parent = current
-					currentIdx = i
-					current = &current.Nodes[i]
-					continue tokensLoop
-				}
-			}
-			panic("Can't duplicate array tag")
-		}
-		parent.Nodes = append(parent.Nodes[:currentIdx], append([]AnyXML{*current}, parent.Nodes[currentIdx:]...)...)
+	if err == nil {
+		err = os.Chmod(file.Name(), mode)
 	}
-
-	for _, field := range jsonInput {
-		if strings.HasPrefix(field.Type, "Decimal") {
-			field.Type = "Number"
-		}
-		switch field.Type {
-		case "Array":
-			arrayNames = append(arrayNames, field.FromKey)
-		case "Date":
-			setXMLValue(field.FromKey, "2020-01-02")
-		case "Number":
-			setXMLValue(field.FromKey, "8.20")
-		case "String":
-			setXMLValue(field.FromKey, "Test")
-		}
-	}
-	for _, n := range arrayNames {
-		duplicateXMLTag(n)
+	if err == nil {
+		err = os.Rename(file.Name(), path)
 	}
-	encoder := xml.NewEncoder(outFile)
-	encoder.Indent("", "    ")
-	encoder.Encode(root)
-	encoder.Flush()
-}
-
-func createParser(parserFilePath string) {
-	jsonInput := readJson()
-	outFile, err := os.Create(parserFilePath)
 	if err != nil {
-		panic(err)
+		os.Remove(file.Name())
 	}
-	defer outFile.Close()
-	var parentArray []string
-	for _, field := range jsonInput {
-		// PND52 have some different in json and xml,so ignore all grossReceipts and implements it manually
-		if strings.HasPrefix(field.FromKey, "TaxFormDetail.Calculate.GrossReceiptsAndTaxComputation.GrossReceiptsBeforeDec.Detail") {
-			continue
-		}
-		if field.ToKey == "" {
-			continue
-		}
-		// set filingNo to string
-		switch field.FromKey {
-		case "TaxForm.Filing.FilingNo", "TaxForm.Filing.FilingType":
-			field.Type = "String"
-		}
-		if field.Type == "Object" && !forceArrayType[field.FromKey] {
-			continue
-		}
-		output := ""
-		afterArrayName := field.ToKey
-		var arrayName string
-		var useArray bool
-		if (len(parentArray)) > 0 {
-			arrayName = parentArray[len(parentArray)-1]
-			if strings.HasPrefix(field.ToKey, arrayName) {
-				afterArrayName = field.ToKey[len(arrayName)+1:]
-				useArray = true
-			}
-		}
+	return err
+}
 
-		if useArray {
-			output += "\tuseArray(\"" + arrayName + "\");\n"
-		} else {
-			output += "\tuseRoot();\n"
-		}
-		if strings.HasPrefix(field.Type, "Decimal") {
-			field.Type = "Number"
-		}
-		if field.Type == "Array" || forceArrayType[field.FromKey] {
-			parentArray = append(parentArray, field.ToKey)
-			output += "\tfinalizeArray(\"" + afterArrayName + "\", \"" + field.ToKey + "\");\n"
-		} else {
-			switch field.Type {
-			case "Date":
-				output += "\tsetDate(\"" + afterArrayName + "\", value);\n"
-			case "Number":
-				output += "\tsetNumber(\"" + afterArrayName + "\", value);\n"
-			case "String":
-				output += "\tsetString(\"" + afterArrayName + "\", value);\n"
-			case "Object":
-			case "Boolean":
-				output += "\tsetBoolean(\"" + afterArrayName + "\", value);\n"
-			default:
-				panic("unknown field type " + field.Type)
-			}
-		}
-		orPanic(outFile.WriteString("if (\"RdForm." + field.FromKey + "\".equals(name)) {\n"))
-		orPanic(outFile.WriteString(output))
-		orPanic(outFile.WriteString("} else "))
-	}
-	orPanic(outFile.WriteString("{}"))
+// formFlags are flags of every command working on one form, they override project file.
+type formFlags struct {
+	spec             string
+	specSheet        string
+	contextLength    int
+	xmlNameMapping   string
+	nameSubstitution string
+	codeList         string
+	overrides        string
 }
 
-func modifyRule() {
-	jsonInput := readJson()
-	var jsonOutput []JsonOutput
-	for _, field := range jsonInput {
-		if field.ToKey != "" {
-			continue
-		}
-		for _, formToKey := range fromToKeyMap {
-			k := formToKey.From
-			v := formToKey.To
-			if strings.HasPrefix(field.FromKey, k) {
-				field.ToKey = v + strings.Join(stringArrayMap(strings.Split(field.FromKey[len(k):], "."), func(str string) string {
-					if newValue, ok := specialName[str]; ok {
-						return newValue
-					}
-					str = strings.Join(stringArrayMap(strings.Split(str, "_"), func(str string) string { // make first letter of each word separated by under score to lower case.
-						if len(str) > 0 {
-							return strings.ToLower(str[:1]) + str[1:]
-						}
-						return str
-					}), "_")
-					return str
-					// rs := []rune(str)
-					// if len(rs) > 0 {
-					// 	rs[0] = unicode.ToLower(rs[0])
-					// 	if rs[len(rs)-1] >= '0' && rs[len(rs)-1] <= '9' { // put leading number at the end. not used anymore
-					// 		str = string(rs)
-					// 		tokens := strings.Split(str, "_")
-					// 		for i := 0; i < len(tokens)/2; i++ {
-					// 			tokens[i], tokens[len(tokens)-i-1] = tokens[len(tokens)-i-1], tokens[i]
-					// 		}
-					// 		str = strings.Join(tokens, "_")
-					// 		rs = []rune(str)
-					// 	}
-					// }
-					// return string(rs)
-				}), ".")
-				if strings.HasSuffix(field.FromKey, ".Detail") { // make all Trailing ".Detail" type with max more than 1 to Array
-					multipleTokens := strings.Split(field.Multiple, "…")
-					if len(multipleTokens) > 1 {
-						max := multipleTokens[1][:strings.Index(multipleTokens[1], "]")]
-						switch max {
-						case "0", "1":
-						default:
-							if field.Type == "Object" {
-								field.Type = "Array"
-							}
-						}
-					} else {
-						log.Println("multiple wrong format")
-					}
-				}
-			}
-		}
-		if field.ToKey == "" {
-			log.Println("ignore xml", field.FromKey)
-		}
-		jsonOutput = append(jsonOutput, field)
-	}
-	writeJson(jsonOutput)
+func (f *formFlags) register(flags *flag.FlagSet) {
+	flags.StringVar(&f.spec, "spec", ``, `xml spec file in csv or xlsx format, override Spec of form`)
+	flags.StringVar(&f.specSheet, "specSheet", ``, `sheet name or 1-based sheet index when spec is xlsx, default to first visible sheet`)
+	flags.IntVar(&f.contextLength, "specContextLength", 0, "length of xml hierarchy, default to number of DEN columns in spec header")
+	flags.StringVar(&f.xmlNameMapping, "xmlNameMapping", ``, `json file represent prefix name mapping, override XMLNameMapping of form`)
+	flags.StringVar(&f.nameSubstitution, "nameSubstitution", ``, `json file represent name substitution, override NameSubstitution of form`)
+	flags.StringVar(&f.codeList, "codeList", ``, `json file of allowed values and patterns of fields, override CodeList of form`)
+	flags.StringVar(&f.overrides, "overrides", ``, `json file of excluded fields, json types, forced arrays and static values, override Overrides of form`)
 }
 
-func excelCsvToJson() {
-	contextLength := *specContextLength
-	file, err := os.Open(*specFile)
-	if err != nil {
-		panic(err)
-	}
-	defer file.Close()
-	reader := csv.NewReader(file)
-	_, err = reader.Read()
-	if err != nil {
-		panic(err)
-	}
-	context := make([]string, contextLength)
-	var result []JsonOutput
-	for {
-		record, err := reader.Read()
+// form read project file named by first argument, without argument form is made of flags only.
+func (f *formFlags) form(args []string) (*rdefiling.Form, error) {
+	form := &rdefiling.Form{}
+	if len(args) > 0 {
+		var err error
+		form, err = rdefiling.ReadForm(args[0])
 		if err != nil {
-			if err == io.EOF {
-				break
-			}
-			panic(err)
+			return nil, err
 		}
-		index := record[0]
-		currentContext := 0
-		for i, r := range record[1 : 1+contextLength] {
-			if r != "" {
-				currentContext = i
-				for j := range context[i:] {
-					context[i+j] = ""
-				}
-				break
-			}
+	} else if f.spec == "" {
+		return nil, errUsage
+	}
+	override := func(value *string, flagValue string) {
+		if flagValue == "" {
+			return
 		}
-		context[currentContext] = stripTagRd(record[1+contextLength])
-		fromKey := joinStripEmpty(context)
-		description := record[2+contextLength]
-		Type := strings.TrimSpace(record[3+contextLength])
-		max := strings.TrimSpace(record[4+contextLength])
-		multiple := strings.TrimSpace(record[5+contextLength])
-		input := record[6+contextLength]
-		// if Type == "Object" {
-		// 	continue
-		// }
-		{
-			isUsed := false
-			switch {
-			case input == "NU": // Not used input
-			case Type == "": // Empty Row and table name
-			case Type == "Type" && index == "Index": // Header
-			default:
-				isUsed = true
-			}
-			if !isUsed {
-				continue
-			}
+		if abs, err := filepath.Abs(flagValue); err == nil {
+			flagValue = abs
 		}
-		result = append(result, JsonOutput{
-			Description: description,
-			FromKey:     fromKey,
-			Index:       index,
-			MaxLength:   max,
-			Multiple:    multiple,
-			Type:        Type,
-			Input:       input,
-		})
+		*value = flagValue
 	}
-	writeJson(result)
-}
-
-func joinStripEmpty(strs []string) string {
-	result := ""
-	prefix := ""
-	for _, str := range strs {
-		if str != "" {
-			result += prefix + strings.TrimSpace(str)
-			prefix = "."
-		}
+	override(&form.Spec, f.spec)
+	override(&form.XMLNameMapping, f.xmlNameMapping)
+	override(&form.NameSubstitution, f.nameSubstitution)
+	override(&form.CodeList, f.codeList)
+	override(&form.Overrides, f.overrides)
+	if f.specSheet != "" {
+		form.SpecSheet = f.specSheet
 	}
-	return result
-}
-
-func stripTagRd(name string) string {
-	name = strings.TrimSpace(name)
-	if len(name) >= 4 && name[:4] == "<rd:" {
-		return name[4 : len(name)-1]
+	if f.contextLength != 0 {
+		form.SpecContextLength = f.contextLength
 	}
-	if len(name) >= 1 && name[:1] == "<" {
-		return name[1 : len(name)-1]
+	if form.XMLNameMapping == "" || form.NameSubstitution == "" {
+		return nil, &rdefiling.Diagnostic{
+			Severity: rdefiling.SeverityError,
+			Stage:    "form",
+			File:     strings.Join(args, ""),
+			Message:  "need both xml name mapping and name substitution file",
+		}
 	}
-	return name
+	return form, nil
 }
 
-func stringArrayMap(strs []string, f func(str string) string) []string {
-	var result []string
-	for _, str := range strs {
-		str = f(str)
-		result = append(result, str)
+// load read spec of form and keep it for diagnostics.
+func (f *formFlags) load(args []string) (*rdefiling.Form, *rdefiling.Spec, error) {
+	form, err := f.form(args)
+	if err != nil {
+		return nil, nil, err
 	}
-	return result
-}
-
-func orPanic(a ...interface{}) {
-	if len(a) > 0 && a[len(a)-1] != nil {
-		panic(a[len(a)-1])
+	spec, err := form.Load()
+	if spec != nil {
+		loadedSpecs = append(loadedSpecs, spec)
 	}
+	return form, spec, err
 }
 
-func prettyPrintXML(location string) {
-	var data []byte
-	func() {
-		inFile, err := os.Open(location)
-		if err != nil {
-			panic(err)
+func hasExt(path string, exts ...string) bool {
+	for _, ext := range exts {
+		if strings.EqualFold(filepath.Ext(path), ext) {
+			return true
 		}
-		defer inFile.Close()
-		data, err = ioutil.ReadAll(inFile)
-		if err != nil {
-			panic(err)
-		}
-	}()
-	func() {
-		outFile, err := os.Create(location)
-		if err != nil {
-			panic(err)
-		}
-		defer outFile.Close()
-		strictxml.FormatIndent(bytes.NewReader(data), outFile, "", "\t")
-	}()
+	}
+	return false
 }
@@ -1,3 +1,3 @@
 module tnd/work/csvToXmlParser
 
-go 1.14
+go 1.19
@@ -0,0 +1,119 @@
+package rdefiling
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// CodeList is allowed values and patterns of fields of a form, written into xsd as xs:enumeration and xs:pattern.
+type CodeList struct {
+	Enumerations map[string][]string // FromKey to allowed values
+	Patterns     map[string]string   // FromKey or rule guideline code to xsd regular expression
+}
+
+// compilePattern compile xsd pattern, which always match whole value, into go regular expression.
+func compilePattern(pattern string) (*regexp.Regexp, error) {
+	return regexp.Compile(`^(?:` + pattern + `)$`)
+}
+
+// ReadCodeList read code list json, name is used in diagnostics.
+func ReadCodeList(reader io.Reader, name string) (*CodeList, error) {
+	data, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, fatalError("codeList", name, 0, "%v", err)
+	}
+	codeList := &CodeList{}
+	if err := json.Unmarshal(data, codeList); err != nil {
+		return nil, fatalError("codeList", name, jsonErrorLine(data, err), "invalid json: %v", err)
+	}
+	for key, pattern := range codeList.Patterns {
+		if _, err := compilePattern(pattern); err != nil {
+			return nil, fatalError("codeList", name, 0, "pattern of %s: %v", key, err)
+		}
+	}
+	return codeList, nil
+}
+
+// ReadCodeListFile read code list json file.
+func ReadCodeListFile(path string) (*CodeList, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fatalError("codeList", path, 0, "%v", err)
+	}
+	defer file.Close()
+	return ReadCodeList(file, path)
+}
+
+// ApplyCodeList set allowed values and pattern of fields. Pattern keyed by FromKey take precedence over pattern of rule code.
+// Value fixed by DEN of spec is replaced by code list, it is warned when not in code list.
+// Code list value which is invalid for type of field is left out with warning.
+func (s *Spec) ApplyCodeList(c *CodeList) {
+	used := map[string]bool{}
+	for i := range s.Fields {
+		field := &s.Fields[i]
+		if values, ok := c.Enumerations[field.FromKey]; ok {
+			used[field.FromKey] = true
+			for _, value := range field.Values {
+				if !stringInSlice(value, values) {
+					s.warnf("codeList", field, "value %q fixed by spec is not in code list", value)
+				}
+			}
+			plain := Field{Type: field.Type, MaxLength: field.MaxLength}
+			field.Values = nil
+			for _, value := range values {
+				if reason := checkSimpleValue(&plain, value); reason != "" {
+					s.warnf("codeList", field, "code list %s, value is left out", reason)
+					continue
+				}
+				field.Values = append(field.Values, value)
+			}
+		}
+		if pattern, ok := c.Patterns[field.FromKey]; ok {
+			used[field.FromKey] = true
+			field.Pattern = pattern
+			continue
+		}
+		for _, rule := range field.Rules {
+			if pattern, ok := c.Patterns[rule]; ok {
+				used[rule] = true
+				field.Pattern = pattern
+				break
+			}
+		}
+	}
+	var unused []string
+	for key := range c.Enumerations {
+		if !used[key] {
+			unused = append(unused, key)
+		}
+	}
+	for key := range c.Patterns {
+		if !used[key] {
+			unused = append(unused, key)
+		}
+	}
+	sort.Strings(unused)
+	if len(unused) > 0 {
+		s.warnf("codeList", nil, "code list entry match no field: %s", strings.Join(unused, ", "))
+	}
+}
+
+// parseDENValues split fixed values from DEN such as "ExchangeDocument.FormType:PND50", values are separated by "|".
+func parseDENValues(den string) []string {
+	index := strings.LastIndex(den, ":")
+	if index < 0 {
+		return nil
+	}
+	var values []string
+	for _, value := range strings.Split(den[index+1:], "|") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
@@ -0,0 +1,108 @@
+package rdefiling
+
+import (
+	"strconv"
+	"strings"
+)
+
+func parseDecimalType(typ string) (precision int, scale int, ok bool) {
+	if !strings.HasPrefix(typ, "Decimal") {
+		return 0, 0, false
+	}
+	a1 := strings.Index(typ, "(")
+	a2 := strings.Index(typ, ",") // decimal delimiter is comma
+	if a2 < 0 {
+		a2 = strings.Index(typ, ".") // decimal delimiter maybe dot
+	}
+	a3 := strings.Index(typ, ")")
+	if a1 < 0 || a2 < a1 || a3 < a2 {
+		return 0, 0, false
+	}
+	precision, err := strconv.Atoi(strings.TrimSpace(typ[a1+1 : a2]))
+	if err != nil {
+		return 0, 0, false
+	}
+	scale, err = strconv.Atoi(strings.TrimSpace(typ[a2+1 : a3]))
+	if err != nil {
+		return 0, 0, false
+	}
+	return precision, scale, true
+}
+
+// parseMultiple parse multiplicity such as "[0…n]" into min and max occurs, max is -1 when unbounded.
+// Empty multiple means [1…1].
+func parseMultiple(multiple string) (min int, max int, ok bool) {
+	multiple = strings.TrimSpace(multiple)
+	if multiple == "" {
+		return 1, 1, true
+	}
+	if multiple[0] != '[' || multiple[len(multiple)-1] != ']' {
+		return 0, 0, false
+	}
+	tokens := strings.Split(multiple[1:len(multiple)-1], "…")
+	if len(tokens) != 2 {
+		return 0, 0, false
+	}
+	min, err := strconv.Atoi(strings.TrimSpace(tokens[0]))
+	if err != nil {
+		return 0, 0, false
+	}
+	switch strings.TrimSpace(tokens[1]) {
+	case "*", "n":
+		max = -1
+	default:
+		max, err = strconv.Atoi(strings.TrimSpace(tokens[1]))
+		if err != nil || max < min {
+			return 0, 0, false
+		}
+	}
+	return min, max, true
+}
+
+func getCell(record []string, column int) string {
+	if column >= 0 && column < len(record) {
+		return record[column]
+	}
+	return ""
+}
+
+func joinStripEmpty(strs []string) string {
+	result := ""
+	prefix := ""
+	for _, str := range strs {
+		if str != "" {
+			result += prefix + strings.TrimSpace(str)
+			prefix = "."
+		}
+	}
+	return result
+}
+
+func stripTagRd(name string) string {
+	name = strings.TrimSpace(name)
+	if len(name) >= 4 && name[:4] == "<rd:" {
+		return name[4 : len(name)-1]
+	}
+	if len(name) >= 1 && name[:1] == "<" {
+		return name[1 : len(name)-1]
+	}
+	return name
+}
+
+func stringArrayMap(strs []string, f func(str string) string) []string {
+	var result []string
+	for _, str := range strs {
+		str = f(str)
+		result = append(result, str)
+	}
+	return result
+}
+
+func stringInSlice(str string, strs []string) bool {
+	for _, s := range strs {
+		if s == str {
+			return true
+		}
+	}
+	return false
+}
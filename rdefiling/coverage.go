@@ -0,0 +1,92 @@
+package rdefiling
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"strings"
+)
+
+// Coverage status of field.
+const (
+	CoverageMapped   = "mapped"
+	CoverageUnmapped = "unmapped"
+	CoverageNotUsed  = "NU"
+)
+
+// FieldCoverage tell whether frontend json can supply field.
+type FieldCoverage struct {
+	Index    string
+	FromKey  string
+	ToKey    string `json:",omitempty"`
+	Type     string
+	Status   string
+	InSample *bool `json:",omitempty"` // whether sample json has value at ToKey, nil without sample or for object
+}
+
+// Coverage list every field of spec with its coverage and mapping entries matching no field.
+type Coverage struct {
+	Fields          []FieldCoverage
+	UnusedMappings  []string `json:",omitempty"`
+	Mapped          int
+	Unmapped        int
+	NotUsed         int
+	MissingInSample int `json:",omitempty"`
+}
+
+// Coverage report mapped, unmapped and NU fields in spec order, NU fields follow used ones.
+// Mapped field is looked up in sample json when sample is not nil.
+func (s *Spec) Coverage(sample io.Reader, sampleName string) (*Coverage, error) {
+	var sampleValue interface{}
+	if sample != nil {
+		data, err := ioutil.ReadAll(sample)
+		if err != nil {
+			return nil, fatalError("coverage", sampleName, 0, "%v", err)
+		}
+		if err := json.Unmarshal(data, &sampleValue); err != nil {
+			return nil, fatalError("coverage", sampleName, jsonErrorLine(data, err), "invalid json: %v", err)
+		}
+	}
+	coverage := &Coverage{UnusedMappings: s.UnusedMappings}
+	for _, field := range s.Fields {
+		c := FieldCoverage{Index: field.Index, FromKey: field.FromKey, ToKey: field.ToKey, Type: field.Type, Status: CoverageMapped}
+		if field.ToKey == "" {
+			c.Status = CoverageUnmapped
+			coverage.Unmapped++
+		} else {
+			coverage.Mapped++
+			if sample != nil && field.Type != "Object" {
+				found := sampleHasValue(sampleValue, strings.Split(field.ToKey, "."))
+				c.InSample = &found
+				if !found {
+					coverage.MissingInSample++
+				}
+			}
+		}
+		coverage.Fields = append(coverage.Fields, c)
+	}
+	for _, field := range s.NotUsed {
+		coverage.Fields = append(coverage.Fields, FieldCoverage{Index: field.Index, FromKey: field.FromKey, Type: field.Type, Status: CoverageNotUsed})
+		coverage.NotUsed++
+	}
+	return coverage, nil
+}
+
+// sampleHasValue report whether json has value at path. Like getJSONByKey, path is looked up in page1 and page2 too,
+// and path going through array is found when any item of array has value.
+func sampleHasValue(value interface{}, path []string) bool {
+	if len(path) == 0 {
+		return value != nil
+	}
+	switch v := value.(type) {
+	case []interface{}:
+		for _, item := range v {
+			if sampleHasValue(item, path) {
+				return true
+			}
+		}
+	case map[string]interface{}:
+		return sampleHasValue(v[path[0]], path[1:]) || sampleHasValue(v["page1"], path) || sampleHasValue(v["page2"], path)
+	}
+	return false
+}
@@ -0,0 +1,88 @@
+package rdefiling
+
+import (
+	"regexp"
+	"strings"
+	"time"
+)
+
+// bangkok is time zone of every date and time in RdForm. Thailand has no daylight saving time,
+// fixed zone avoid depending on tz database which is missing on some Windows machines.
+var bangkok = time.FixedZone("Asia/Bangkok", 7*60*60)
+
+var (
+	dateTimePattern = regexp.MustCompile(`^[0-9]{4}-[0-9]{2}-[0-9]{2}T[0-9]{2}:[0-9]{2}:[0-9]{2}(\.[0-9]+)?(Z|[+-][0-9]{2}:[0-9]{2})?$`)
+	timePattern     = regexp.MustCompile(`^[0-9]{2}:[0-9]{2}:[0-9]{2}(\.[0-9]+)?(Z|[+-][0-9]{2}:[0-9]{2})?$`)
+	yearPattern     = regexp.MustCompile(`^-?[0-9]{4,}(Z|[+-][0-9]{2}:[0-9]{2})?$`)
+)
+
+// normalizeSpecType unify spelling of date and time types in Type column of spec, and guess DateTime, Time
+// and Year of field typed Date from its element name, such as IssueDateTime.
+// Only types read from spec are guessed, type given by user is taken as written, see normalizeTypeSpelling.
+func normalizeSpecType(fromKey string, typ string) string {
+	typ = normalizeTypeSpelling(typ)
+	if typ != "Date" {
+		return typ
+	}
+	name := fromKey[strings.LastIndex(fromKey, ".")+1:]
+	switch {
+	case strings.HasSuffix(name, "DateTime"):
+		return "DateTime"
+	case strings.HasSuffix(name, "Time"):
+		return "Time"
+	case strings.HasSuffix(name, "Year"):
+		return "Year"
+	}
+	return "Date"
+}
+
+// normalizeTypeSpelling unify spelling of date and time types, such as timestamp or gYear.
+func normalizeTypeSpelling(typ string) string {
+	switch strings.ToLower(strings.Join(strings.Fields(typ), "")) {
+	case "datetime", "timestamp":
+		return "DateTime"
+	case "time":
+		return "Time"
+	case "year", "gyear":
+		return "Year"
+	case "date":
+		return "Date"
+	}
+	return typ
+}
+
+// parseTimestamp parse ISO 8601 timestamp of frontend such as 2021-02-15T06:51:59.728Z,
+// timestamp without zone is time in Bangkok.
+func parseTimestamp(value string) (time.Time, bool) {
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05.999999999Z0700"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t.In(bangkok), true
+		}
+	}
+	for _, layout := range []string{"2006-01-02T15:04:05.999999999", "2006-01-02 15:04:05.999999999"} {
+		if t, err := time.ParseInLocation(layout, value, bangkok); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// formatTemporal convert timestamp of frontend into lexical form of Date, DateTime, Time or Year in Bangkok time,
+// value which is not timestamp is kept.
+func formatTemporal(typ string, value string) string {
+	t, ok := parseTimestamp(strings.TrimSpace(value))
+	if !ok {
+		return value
+	}
+	switch typ {
+	case "Date":
+		return t.Format("2006-01-02")
+	case "DateTime":
+		return t.Format("2006-01-02T15:04:05.999999999-07:00")
+	case "Time":
+		return t.Format("15:04:05.999999999-07:00")
+	case "Year":
+		return t.Format("2006")
+	}
+	return value
+}
@@ -0,0 +1,136 @@
+package rdefiling
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+)
+
+// Diagnostic is a problem found in spec, mapping or data file.
+type Diagnostic struct {
+	Severity string
+	Stage    string
+	File     string `json:",omitempty"`
+	Line     int    `json:",omitempty"`
+	Index    string `json:",omitempty"`
+	FromKey  string `json:",omitempty"`
+	Rule     string `json:",omitempty"` // rule guideline code of validation error
+	Path     string `json:",omitempty"` // location in validated xml
+	Message  string
+}
+
+func (d *Diagnostic) Error() string {
+	location := d.File
+	if d.Line > 0 {
+		location += ":" + strconv.Itoa(d.Line)
+	}
+	if location != "" {
+		location += ": "
+	}
+	subject := ""
+	for _, s := range []string{d.Index, d.FromKey, d.Rule, d.Path} {
+		if s != "" {
+			subject += s + " "
+		}
+	}
+	return fmt.Sprintf("%s%s: [%s] %s%s", location, d.Severity, d.Stage, subject, d.Message)
+}
+
+// Diagnostics collect problems and keep going where it can.
+type Diagnostics []Diagnostic
+
+func (ds *Diagnostics) add(severity, stage string, file string, field *Field, format string, a ...interface{}) *Diagnostic {
+	d := Diagnostic{
+		Severity: severity,
+		Stage:    stage,
+		File:     file,
+		Message:  fmt.Sprintf(format, a...),
+	}
+	if field != nil {
+		d.Line = field.Line
+		d.Index = field.Index
+		d.FromKey = field.FromKey
+	}
+	*ds = append(*ds, d)
+	return &(*ds)[len(*ds)-1]
+}
+
+// HasError report whether there is any diagnostic of error severity.
+func (ds Diagnostics) HasError() bool {
+	for _, d := range ds {
+		if d.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// WriteText print one diagnostic per line followed by summary.
+func (ds Diagnostics) WriteText(writer io.Writer) error {
+	errorCount := 0
+	for i := range ds {
+		if ds[i].Severity == SeverityError {
+			errorCount++
+		}
+		if _, err := fmt.Fprintln(writer, ds[i].Error()); err != nil {
+			return err
+		}
+	}
+	if len(ds) == 0 {
+		return nil
+	}
+	_, err := fmt.Fprintf(writer, "%d error(s), %d warning(s)\n", errorCount, len(ds)-errorCount)
+	return err
+}
+
+func (ds Diagnostics) WriteJSON(writer io.Writer) error {
+	encoder := json.NewEncoder(writer)
+	encoder.SetIndent("", "    ")
+	if ds == nil {
+		ds = Diagnostics{}
+	}
+	return encoder.Encode(ds)
+}
+
+func fatalError(stage string, file string, line int, format string, a ...interface{}) error {
+	return &Diagnostic{
+		Severity: SeverityError,
+		Stage:    stage,
+		File:     file,
+		Line:     line,
+		Message:  fmt.Sprintf(format, a...),
+	}
+}
+
+// jsonErrorLine find line of json syntax or type error in data, return 0 if unknown.
+func jsonErrorLine(data []byte, err error) int {
+	var offset int64
+	switch e := err.(type) {
+	case *json.SyntaxError:
+		offset = e.Offset
+	case *json.UnmarshalTypeError:
+		offset = e.Offset
+	default:
+		return 0
+	}
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	return bytes.Count(data[:offset], []byte("\n")) + 1
+}
+
+// xmlErrorLine return line of xml syntax error, 0 if unknown.
+func xmlErrorLine(err error) int {
+	if syntaxError, ok := err.(*xml.SyntaxError); ok {
+		return syntaxError.Line
+	}
+	return 0
+}
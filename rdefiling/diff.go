@@ -0,0 +1,86 @@
+package rdefiling
+
+import (
+	"strconv"
+	"strings"
+)
+
+// FieldChange is difference of one field, matched by FromKey, between two specs.
+type FieldChange struct {
+	FromKey    string
+	Change     string   // added, removed or changed
+	Old        *Field   `json:",omitempty"`
+	New        *Field   `json:",omitempty"`
+	Attributes []string `json:",omitempty"` // changed attributes
+}
+
+var diffAttributes = []struct {
+	Name  string
+	Value func(*Field) string
+}{
+	{"Type", func(f *Field) string { return f.Type }},
+	{"MaxLength", func(f *Field) string { return f.MaxLength }},
+	{"Multiple", func(f *Field) string { return f.Multiple }},
+	{"Input", func(f *Field) string { return f.Input }},
+	{"Output", func(f *Field) string { return f.Output }},
+	{"Rules", func(f *Field) string { return strings.Join(f.Rules, ",") }},
+	{"ToKey", func(f *Field) string { return f.ToKey }},
+	{"Description", func(f *Field) string { return f.Description }},
+}
+
+// diffKeys make key of each field, FromKey repeated in spec is numbered by its occurrence.
+func diffKeys(fields []Field) []string {
+	count := map[string]int{}
+	var keys []string
+	for _, field := range fields {
+		count[field.FromKey]++
+		keys = append(keys, field.FromKey+"#"+strconv.Itoa(count[field.FromKey]))
+	}
+	return keys
+}
+
+// DiffSpecs list fields added, removed or changed from old to new, in order of new spec followed by removed fields.
+func DiffSpecs(old, new *Spec) []FieldChange {
+	oldKeys := diffKeys(old.Fields)
+	newKeys := diffKeys(new.Fields)
+	oldFields := map[string]*Field{}
+	for i, key := range oldKeys {
+		oldFields[key] = &old.Fields[i]
+	}
+	newFields := map[string]bool{}
+	var changes []FieldChange
+	for i, key := range newKeys {
+		newField := &new.Fields[i]
+		newFields[key] = true
+		oldField, ok := oldFields[key]
+		if !ok {
+			changes = append(changes, FieldChange{FromKey: newField.FromKey, Change: "added", New: newField})
+			continue
+		}
+		var attributes []string
+		for _, attribute := range diffAttributes {
+			if attribute.Value(oldField) != attribute.Value(newField) {
+				attributes = append(attributes, attribute.Name)
+			}
+		}
+		if len(attributes) > 0 {
+			changes = append(changes, FieldChange{FromKey: newField.FromKey, Change: "changed", Old: oldField, New: newField, Attributes: attributes})
+		}
+	}
+	for i, key := range oldKeys {
+		if !newFields[key] {
+			changes = append(changes, FieldChange{FromKey: old.Fields[i].FromKey, Change: "removed", Old: &old.Fields[i]})
+		}
+	}
+	return changes
+}
+
+// DiffValue return value of changed attribute for printing.
+func DiffValue(field *Field, attribute string) string {
+	for _, a := range diffAttributes {
+		if a.Name == attribute {
+			return a.Value(field)
+		}
+	}
+	return ""
+}
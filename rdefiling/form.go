@@ -0,0 +1,178 @@
+package rdefiling
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// FormFile is name of project file in every form directory.
+const FormFile = "form.json"
+
+// Form is project file of one form. File paths are relative to Dir, the directory of project file.
+type Form struct {
+	Dir               string `json:"-"`
+	Name              string // default to name of Dir
+	Spec              string // spec in csv or xlsx format
+	SpecSheet         string `json:",omitempty"`
+	SpecContextLength int    `json:",omitempty"`
+	XMLNameMapping    string
+	NameSubstitution  string
+	TestData          string            `json:",omitempty"` // json of frontend used as sample and template of xml to json conversion
+	RuleCatalogue     string            `json:",omitempty"`
+	CodeList          string            `json:",omitempty"` // allowed values and patterns of fields
+	Overrides         string            `json:",omitempty"` // excluded fields, json types, forced arrays and static values of generators
+	Types             map[string]string `json:",omitempty"` // override Type of field by FromKey, such as DateTime
+	SignatureElements []string          `json:",omitempty"` // FromKey of objects which may carry ds:Signature, default to RdForm only
+}
+
+// Default file names of form directory without project file.
+const (
+	DefaultXMLNameMapping   = "xmlNameMapping.json"
+	DefaultNameSubstitution = "nameSubstitution.json"
+	DefaultTestData         = "testData.json"
+	DefaultCodeList         = "codeList.json"
+	DefaultOverrides        = "overrides.json"
+)
+
+// ReadForm read project file, path is either form directory or project file itself.
+// Directory without project file is read as form of its only csv or xlsx spec and default mapping files.
+func ReadForm(path string) (*Form, error) {
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		if _, err := os.Stat(filepath.Join(path, FormFile)); os.IsNotExist(err) {
+			return inferForm(path)
+		}
+		path = filepath.Join(path, FormFile)
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fatalError("form", path, 0, "%v", err)
+	}
+	form := &Form{}
+	if err := json.Unmarshal(data, form); err != nil {
+		return nil, fatalError("form", path, jsonErrorLine(data, err), "invalid json: %v", err)
+	}
+	form.Dir = filepath.Dir(path)
+	if form.Name == "" {
+		if abs, err := filepath.Abs(form.Dir); err == nil {
+			form.Name = filepath.Base(abs)
+		}
+	}
+	if form.Spec == "" {
+		return nil, fatalError("form", path, 0, "Spec is required")
+	}
+	return form, nil
+}
+
+// Path resolve file listed in project file, empty file stay empty.
+func (f *Form) Path(file string) string {
+	if file == "" || filepath.IsAbs(file) {
+		return file
+	}
+	return filepath.Join(f.Dir, file)
+}
+
+// Load read spec of form and apply its code list, overrides and name mapping when listed.
+func (f *Form) Load() (*Spec, error) {
+	spec, err := ReadSpec(f.Path(f.Spec), SpecOptions{Sheet: f.SpecSheet, ContextLength: f.SpecContextLength})
+	if err != nil {
+		return nil, err
+	}
+	if len(f.Types) > 0 {
+		spec.ApplyTypes(f.Types)
+	}
+	if f.CodeList != "" {
+		codeList, err := ReadCodeListFile(f.Path(f.CodeList))
+		if err != nil {
+			return spec, err
+		}
+		spec.ApplyCodeList(codeList)
+	}
+	if f.Overrides != "" {
+		overrides, err := ReadOverridesFile(f.Path(f.Overrides))
+		if err != nil {
+			return spec, err
+		}
+		spec.ApplyOverrides(overrides)
+	}
+	if f.XMLNameMapping == "" && f.NameSubstitution == "" {
+		return spec, nil
+	}
+	mapping, err := ReadNameMapping(f.Path(f.XMLNameMapping), f.Path(f.NameSubstitution))
+	if err != nil {
+		return spec, err
+	}
+	spec.ApplyMapping(mapping)
+	return spec, nil
+}
+
+func inferForm(dir string) (*Form, error) {
+	form := &Form{Dir: dir, Name: filepath.Base(dir)}
+	if abs, err := filepath.Abs(dir); err == nil {
+		form.Name = filepath.Base(abs)
+	}
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, fatalError("form", dir, 0, "%v", err)
+	}
+	var specs []string
+	for _, file := range files {
+		switch {
+		case file.IsDir():
+		case file.Name() == DefaultXMLNameMapping:
+			form.XMLNameMapping = file.Name()
+		case file.Name() == DefaultNameSubstitution:
+			form.NameSubstitution = file.Name()
+		case file.Name() == DefaultTestData:
+			form.TestData = file.Name()
+		case file.Name() == DefaultCodeList:
+			form.CodeList = file.Name()
+		case file.Name() == DefaultOverrides:
+			form.Overrides = file.Name()
+		case strings.EqualFold(filepath.Ext(file.Name()), ".csv"):
+			specs = append(specs, file.Name())
+		}
+	}
+	if len(specs) == 0 { // xlsx is used only when there is no csv export
+		for _, file := range files {
+			if !file.IsDir() && strings.EqualFold(filepath.Ext(file.Name()), ".xlsx") {
+				specs = append(specs, file.Name())
+			}
+		}
+	}
+	switch len(specs) {
+	case 0:
+		return nil, fatalError("form", dir, 0, "no %s and no csv or xlsx spec in directory", FormFile)
+	case 1:
+		form.Spec = specs[0]
+	default:
+		return nil, fatalError("form", dir, 0, "more than one spec %s, list Spec in %s", strings.Join(specs, ", "), FormFile)
+	}
+	return form, nil
+}
+
+// FindForms list every directory below root, root included, which has project file or name mapping file.
+func FindForms(root string) ([]string, error) {
+	var dirs []string
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			return nil
+		}
+		for _, name := range []string{FormFile, DefaultXMLNameMapping} {
+			if _, err := os.Stat(filepath.Join(path, name)); err == nil {
+				dirs = append(dirs, path)
+				break
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fatalError("form", root, 0, "%v", err)
+	}
+	return dirs, nil
+}
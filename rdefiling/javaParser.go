@@ -0,0 +1,77 @@
+package rdefiling
+
+import (
+	"io"
+	"strings"
+)
+
+// JavaParser write part of java parser which copy value of each xml element into frontend json.
+func (s *Spec) JavaParser(writer io.Writer) error {
+	jsonInput := s.Fields
+	var writeErr error
+	write := func(str string) {
+		if writeErr == nil {
+			_, writeErr = io.WriteString(writer, str)
+		}
+	}
+	var parentArray []string
+	for _, field := range jsonInput {
+		if s.excluded(field.FromKey) || field.ToKey == "" {
+			continue
+		}
+		field.Type = s.jsonType(&field)
+		if field.Type == "Object" && !s.ForceArray[field.FromKey] {
+			continue
+		}
+		output := ""
+		afterArrayName := field.ToKey
+		var arrayName string
+		var useArray bool
+		if (len(parentArray)) > 0 {
+			arrayName = parentArray[len(parentArray)-1]
+			if strings.HasPrefix(field.ToKey, arrayName) {
+				afterArrayName = field.ToKey[len(arrayName)+1:]
+				useArray = true
+			}
+		}
+
+		if useArray {
+			output += "\tuseArray(\"" + arrayName + "\");\n"
+		} else {
+			output += "\tuseRoot();\n"
+		}
+		if strings.HasPrefix(field.Type, "Decimal") {
+			field.Type = "Number"
+		}
+		if field.Type == "Array" || s.ForceArray[field.FromKey] {
+			parentArray = append(parentArray, field.ToKey)
+			output += "\tfinalizeArray(\"" + afterArrayName + "\", \"" + field.ToKey + "\");\n"
+		} else {
+			switch field.Type {
+			case "Date":
+				output += "\tsetDate(\"" + afterArrayName + "\", value);\n"
+			case "DateTime":
+				output += "\tsetDateTime(\"" + afterArrayName + "\", value);\n"
+			case "Time":
+				output += "\tsetTime(\"" + afterArrayName + "\", value);\n"
+			case "Year":
+				output += "\tsetYear(\"" + afterArrayName + "\", value);\n"
+			case "Number":
+				output += "\tsetNumber(\"" + afterArrayName + "\", value);\n"
+			case "String":
+				output += "\tsetString(\"" + afterArrayName + "\", value);\n"
+			case "Object":
+			case "Boolean":
+				output += "\tsetBoolean(\"" + afterArrayName + "\", value);\n"
+			default:
+				s.errorf("parser", &field, "unknown field type %q", field.Type)
+				continue
+			}
+		}
+		write("if (\"RdForm." + field.FromKey + "\".equals(name)) {\n")
+		write(output)
+		write("} else ")
+	}
+	write("{}")
+	return writeErr
+}
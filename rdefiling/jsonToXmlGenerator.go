@@ -1,15 +1,21 @@
-package main
+package rdefiling
 
 import (
 	"encoding/json"
 	"encoding/xml"
-	"fmt"
-	"log"
-	"os"
+	"io"
+	"io/ioutil"
 	"strconv"
 	"strings"
 )
 
+type AnyXML struct {
+	XMLName xml.Name
+	Nodes   []AnyXML   `xml:",any"`
+	Attrs   []xml.Attr `xml:",attr,any"`
+	Data    string     `xml:",chardata"`
+}
+
 func getJSONByKey(value interface{}, name string) interface{} {
 	nameDotIndex := strings.Index(name, ".")
 	var result interface{}
@@ -32,21 +38,16 @@ func getJSONByKey(value interface{}, name string) interface{} {
 	return result
 }
 
-func jsonToXML() {
-	jsonInput := readJson()
-	outFile, err := os.Create(*xmlTestDataFile)
-	if err != nil {
-		panic(err)
-	}
-	defer outFile.Close()
-
+// JSONToXML convert frontend json to RdForm xml, name is name of json used in diagnostics.
+func (s *Spec) JSONToXML(reader io.Reader, name string, writer io.Writer) error {
+	jsonInput := s.Fields
 	var jsonValue interface{}
-	if testDataInput, err := os.Open(*jsonTestDataFile); err == nil {
-		json.NewDecoder(testDataInput).Decode(&jsonValue)
-		testDataInput.Close()
+	if testData, err := ioutil.ReadAll(reader); err == nil {
+		if err := json.Unmarshal(testData, &jsonValue); err != nil {
+			return fatalError("json", name, jsonErrorLine(testData, err), "invalid json: %v", err)
+		}
 	} else {
-		log.Println("test data file can't be open")
-		return
+		return fatalError("json", name, 0, "%v", err)
 	}
 
 	generateXMLParentNode := func(name string) AnyXML {
@@ -77,7 +78,7 @@ func jsonToXML() {
 		}
 		node.Nodes = append(node.Nodes, child)
 	}
-	generateXMLElementOfType := func(name string, typ string, data interface{}) AnyXML {
+	generateXMLElementOfType := func(field *Field, name string, typ string, data interface{}) (AnyXML, bool) {
 		xmlElement := generateXMLParentNode(name)
 		value := ""
 		switch d := data.(type) {
@@ -88,50 +89,44 @@ func jsonToXML() {
 				value = "false"
 			}
 		case float64:
-			if typ == "Number" {
+			if typ == "Number" || typ == "Year" {
 				value = strconv.FormatFloat(d, 'f', 0, 64)
 			} else {
 				if strings.HasPrefix(typ, "Decimal") {
-					decimalSpec := strings.TrimSpace(typ[len("Decimal"):])
-					decimalSpec = decimalSpec[1 : len(decimalSpec)-1]
-					minMax := strings.Split(decimalSpec, ",")
-					if v, err := strconv.ParseInt(strings.TrimSpace(minMax[1]), 10, 64); err == nil {
-						value = strconv.FormatFloat(d, 'f', int(v), 64)
+					if _, scale, ok := parseDecimalType(typ); ok {
+						value = strconv.FormatFloat(d, 'f', scale, 64)
 					} else {
-						panic("invalid decimal spec " + decimalSpec)
+						s.errorf("json", field, "invalid decimal spec %q", typ)
+						return xmlElement, false
 					}
 				} else {
-					panic("invalid float64 type " + typ)
+					s.errorf("json", field, "number %v is not allowed for type %q", d, typ)
+					return xmlElement, false
 				}
 			}
 		case string:
-			value = d
+			value = formatTemporal(typ, d)
 		default:
 			// if vc, err := json.Marshal(value); err != nil {
 			// 	value = string(vc)
 			// }
-			log.Println(name, typ, data)
-			panic(fmt.Sprint("unknown value type ", d))
+			s.errorf("json", field, "unknown value type %T of %v", d, d)
+			return xmlElement, false
 		}
 		xmlElement.Data = value
-		return xmlElement
+		return xmlElement, true
 	}
 
-	var transferValues func(*AnyXML, interface{}, []JsonOutput, string, string)
-	transferValues = func(parent *AnyXML, src interface{}, datas []JsonOutput, fromKeyPrefix, toKeyPrefix string) {
+	var transferValues func(*AnyXML, interface{}, []Field, string, string)
+	transferValues = func(parent *AnyXML, src interface{}, datas []Field, fromKeyPrefix, toKeyPrefix string) {
 		result := parent
 		for datasIndex := 0; datasIndex < len(datas); datasIndex++ {
 			data := datas[datasIndex]
-			// PND52 have some different in json and xml,so ignore all grossReceipts and implements it manually
-			if strings.HasPrefix(data.FromKey, "TaxFormDetail.Calculate.GrossReceiptsAndTaxComputation.GrossReceiptsBeforeDec.Detail") {
+			if s.excluded(data.FromKey) {
 				continue
 			}
-			typ := data.Type
-			switch data.FromKey {
-			case "TaxForm.Filing.FilingNo", "TaxForm.Filing.FilingType":
-				typ = "String"
-			}
-			if forceArrayType[data.FromKey] {
+			typ := s.jsonType(&data)
+			if s.ForceArray[data.FromKey] {
 				typ = "Array"
 			}
 			if typ == "Object" {
@@ -140,9 +135,20 @@ func jsonToXML() {
 			if !strings.HasPrefix(data.FromKey, fromKeyPrefix) {
 				break
 			}
-			toKey := data.ToKey[len(toKeyPrefix):]
+			if data.ToKey != "" && !strings.HasPrefix(data.ToKey, toKeyPrefix) {
+				s.warnf("json", &data, "ToKey %s is not under array %s", data.ToKey, toKeyPrefix)
+				continue
+			}
+			toKey := ""
+			if data.ToKey != "" {
+				toKey = data.ToKey[len(toKeyPrefix):]
+			}
 			fromKey := data.FromKey[len(fromKeyPrefix):]
-			if typ == "Array" {
+			if typ == "Array" && data.ToKey == "" {
+				for datasIndex+1 < len(datas) && strings.HasPrefix(datas[datasIndex+1].FromKey, data.FromKey+".") {
+					datasIndex++
+				}
+			} else if typ == "Array" {
 				arrayData := getJSONByKey(src, toKey)
 				newFromKeyPrefix, newToKeyPrefix := data.FromKey+".", data.ToKey+"."
 				if arrayData != nil {
@@ -153,35 +159,31 @@ func jsonToXML() {
 							putXMLElement(result, fromKey, xmlElementInArray)
 						}
 					} else {
-						panic(fmt.Sprint("expect array but got ", arrayData))
+						s.errorf("json", &data, "expect array at %s but got %v", data.ToKey, arrayData)
 					}
-				} else {
-					log.Println("ArrayData nil")
 				}
 				for datasIndex < len(datas) && strings.HasPrefix(datas[datasIndex].FromKey, data.FromKey) {
 					datasIndex++
 				}
 				datasIndex--
 			} else {
-				value := getJSONByKey(src, toKey)
-				if value != nil {
-					xmlElement := generateXMLElementOfType(elementNameFromKey(fromKey), typ, value)
-					putXMLElement(result, fromKey, xmlElement)
+				var value interface{}
+				if data.ToKey != "" {
+					value = getJSONByKey(src, toKey)
+				}
+				if value = s.xmlValue(data.FromKey, value); value != nil {
+					if xmlElement, ok := generateXMLElementOfType(&data, elementNameFromKey(fromKey), typ, value); ok {
+						putXMLElement(result, fromKey, xmlElement)
+					}
 				}
 			}
 		}
 	}
 	rdForm := generateXMLParentNode(elementNameFromKey("RdForm"))
-	rdForm.Attrs = append(rdForm.Attrs, xml.Attr{Name: xml.Name{Local: "xmlns:rd"}, Value: xmlNameSpace})
-
-	putStaticData := func(parent *AnyXML, name string, value string) { // this function put some static data into xml test data
-		putXMLElement(parent, name, generateXMLElementOfType(elementNameFromKey(name), "String", value))
-	}
-	putStaticData(&rdForm, "ExchangeDocumentContext.GuidelineSpecifiedDocumentContextParameter.Id", "123456")
-	//putStaticData(&rdForm, "ExchangeDocument.Name", "แบบแสดงรายการภาษีเงินได้บริษัทหรือห้างหุ้นส่วนนิติบุคคล")
+	rdForm.Attrs = append(rdForm.Attrs, xml.Attr{Name: xml.Name{Local: "xmlns:rd"}, Value: XMLNameSpace})
 
 	transferValues(&rdForm, jsonValue, jsonInput, "", "")
-	encoder := xml.NewEncoder(outFile)
+	encoder := xml.NewEncoder(writer)
 	encoder.Indent("", "    ")
-	encoder.Encode(rdForm)
+	return encoder.Encode(rdForm)
 }
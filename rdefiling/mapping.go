@@ -0,0 +1,198 @@
+package rdefiling
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+)
+
+type FromToKey struct {
+	From string
+	To   string
+}
+
+type fromToKeySorter []FromToKey
+
+func (ftk fromToKeySorter) Len() int {
+	return len(ftk)
+}
+func (ftk fromToKeySorter) Swap(a, b int) {
+	ftk[a], ftk[b] = ftk[b], ftk[a]
+}
+func (ftk fromToKeySorter) Less(a, b int) bool {
+	return ftk[a].From < ftk[b].From
+}
+
+// NameMapping map xml path in spec to json path of frontend.
+// Prefixes map prefix of FromKey to prefix of ToKey, Substitutions rename one segment of the rest of path.
+// Rules come from extended mapping files, see MappingRule.
+type NameMapping struct {
+	File              string // prefix mapping file, for diagnostics
+	Prefixes          []FromToKey
+	PrefixRules       []MappingRule
+	Substitutions     map[string]string
+	SubstitutionRules []MappingRule
+	Case              string // default case strategy of segment, lowerFirst when empty
+}
+
+// matchPrefix report whether prefix match fromKey at segment boundary, so TaxPayer.Web does not match TaxPayer.Website.
+func matchPrefix(fromKey, prefix string) bool {
+	if !strings.HasPrefix(fromKey, prefix) {
+		return false
+	}
+	return len(fromKey) == len(prefix) || strings.HasSuffix(prefix, ".") || fromKey[len(prefix)] == '.'
+}
+
+// match find the most specific prefix of fromKey, the longest one. Other prefix naming the same path,
+// written with or without trailing dot, is returned as ambiguous.
+func (m *NameMapping) match(fromKey string) (int, []int) {
+	best := -1
+	var matches []int
+	for i, prefix := range m.Prefixes {
+		if !matchPrefix(fromKey, prefix.From) {
+			continue
+		}
+		matches = append(matches, i)
+		if best < 0 || len(prefix.From) > len(m.Prefixes[best].From) {
+			best = i
+		}
+	}
+	var ambiguous []int
+	if best >= 0 {
+		path := strings.TrimSuffix(m.Prefixes[best].From, ".")
+		for _, i := range matches {
+			if i != best && strings.TrimSuffix(m.Prefixes[i].From, ".") == path {
+				ambiguous = append(ambiguous, i)
+			}
+		}
+	}
+	return best, ambiguous
+}
+
+func readStringMap(reader io.Reader, name string) (map[string]string, error) {
+	data, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, fatalError("mapping", name, 0, "%v", err)
+	}
+	var nameMapping map[string]interface{}
+	if err := json.Unmarshal(data, &nameMapping); err != nil {
+		return nil, fatalError("mapping", name, jsonErrorLine(data, err), "invalid json: %v", err)
+	}
+	result := map[string]string{}
+	for key, v := range nameMapping {
+		value, ok := v.(string)
+		if !ok {
+			return nil, fatalError("mapping", name, 0, "value of %q must be string but got %v", key, v)
+		}
+		result[key] = value
+	}
+	return result, nil
+}
+
+// ParseNameMapping read prefix mapping (xmlNameMapping.json) and name substitution (nameSubstitution.json),
+// each is either plain json object of names or extended file with Rules.
+func ParseNameMapping(mapping io.Reader, mappingName string, substitution io.Reader, substitutionName string) (*NameMapping, error) {
+	prefixes, err := readMappingFile(mapping, mappingName, "Prefixes")
+	if err != nil {
+		return nil, err
+	}
+	substitutions, err := readMappingFile(substitution, substitutionName, "Substitutions")
+	if err != nil {
+		return nil, err
+	}
+	m := &NameMapping{
+		File:              mappingName,
+		PrefixRules:       prefixes.Rules,
+		Substitutions:     substitutions.Substitutions,
+		SubstitutionRules: substitutions.Rules,
+		Case:              substitutions.Case,
+	}
+	for key, value := range prefixes.Prefixes {
+		m.Prefixes = append(m.Prefixes, FromToKey{From: key, To: value})
+	}
+	sort.Sort(fromToKeySorter(m.Prefixes))
+	return m, nil
+}
+
+// ReadNameMapping read prefix mapping and name substitution files.
+func ReadNameMapping(mappingFile, substitutionFile string) (*NameMapping, error) {
+	mapping, err := os.Open(mappingFile)
+	if err != nil {
+		return nil, fatalError("mapping", mappingFile, 0, "%v", err)
+	}
+	defer mapping.Close()
+	substitution, err := os.Open(substitutionFile)
+	if err != nil {
+		return nil, fatalError("mapping", substitutionFile, 0, "%v", err)
+	}
+	defer substitution.Close()
+	return ParseNameMapping(mapping, mappingFile, substitution, substitutionFile)
+}
+
+// ApplyMapping fill ToKey of every field which has no ToKey yet, using the longest prefix matching FromKey.
+// Prefix rule is used instead when it matches longer prefix than literal one.
+// Object ending with ".Detail" which can occur more than once become Array.
+// Prefix naming the same path as used one and prefix used by no field are reported.
+func (s *Spec) ApplyMapping(m *NameMapping) {
+	used := make([]bool, len(m.Prefixes)+len(m.PrefixRules)) // literal prefixes followed by prefix rules
+	reported := map[[2]int]bool{}
+	for i := range s.Fields {
+		field := &s.Fields[i]
+		if field.ToKey != "" {
+			continue
+		}
+		best, ambiguous := m.match(field.FromKey)
+		for _, other := range ambiguous {
+			if !reported[[2]int{best, other}] {
+				reported[[2]int{best, other}] = true
+				d := s.Diagnostics.add(SeverityWarning, "mapping", m.File, nil, "mapping %q and %q name the same path, %q is used",
+					m.Prefixes[other].From, m.Prefixes[best].From, m.Prefixes[best].From)
+				d.Index, d.FromKey = field.Index, field.FromKey // line of field is line of spec, not of mapping
+			}
+		}
+		entry, length, toKey := best, 0, ""
+		if best >= 0 {
+			length, toKey = len(m.Prefixes[best].From), m.Prefixes[best].To
+		}
+		if rule, ruleLength, ruleToKey := m.matchRule(field.FromKey); rule >= 0 && ruleLength > length {
+			entry, length, toKey = len(m.Prefixes)+rule, ruleLength, ruleToKey
+		}
+		if entry < 0 {
+			continue
+		}
+		used[entry] = true
+		segments := strings.Split(field.FromKey[length:], ".")
+		end := length // end of segment in FromKey, rules with Under look at FromKey up to it
+		for i, segment := range segments {
+			end += len(segment)
+			segments[i] = m.substitute(segment, field.FromKey[:end])
+			end++
+		}
+		field.ToKey = toKey + strings.Join(segments, ".")
+		if strings.HasSuffix(field.FromKey, ".Detail") { // make all Trailing ".Detail" type with max more than 1 to Array
+			if _, max, ok := parseMultiple(field.Multiple); !ok {
+				s.warnf("mapping", field, "multiple %q wrong format", field.Multiple)
+			} else if max < 0 || max > 1 {
+				if field.Type == "Object" {
+					field.Type = "Array"
+				}
+			}
+		}
+	}
+	for i, used := range used {
+		if used {
+			continue
+		}
+		if i < len(m.Prefixes) {
+			s.UnusedMappings = append(s.UnusedMappings, m.Prefixes[i].From)
+			s.Diagnostics.add(SeverityWarning, "mapping", m.File, nil, "mapping %q is not used by any field", m.Prefixes[i].From)
+		} else {
+			rule := m.PrefixRules[i-len(m.Prefixes)]
+			s.UnusedMappings = append(s.UnusedMappings, rule.Match)
+			s.Diagnostics.add(SeverityWarning, "mapping", m.File, nil, "mapping rule %d %q is not used by any field", i-len(m.Prefixes)+1, rule.Match)
+		}
+	}
+}
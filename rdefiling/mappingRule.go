@@ -0,0 +1,224 @@
+package rdefiling
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// Case strategies of segment of ToKey.
+const (
+	CaseLowerFirst = "lowerFirst" // lower first letter of each word separated by under score, default
+	CaseCamel      = "camelCase"  // join words separated by under score, lower first word and upper the others
+	CaseSnake      = "snake"      // lower case words split at upper case letters, joined by under score
+	CaseKeep       = "keep"
+)
+
+// MappingRule is one ordered rule of extended mapping file.
+// Rule of xmlNameMapping.json map prefix of FromKey matched by Match to ToKey prefix Replace.
+// Rule of nameSubstitution.json rename segment equal to Segment or matched by Match to Replace, then convert it by Case.
+// Rule with Case only convert every segment, Under limit rule to segments below given FromKey prefix.
+type MappingRule struct {
+	Under   string `json:",omitempty"`
+	Segment string `json:",omitempty"`
+	Match   string `json:",omitempty"` // regular expression matching whole segment or start of FromKey
+	Replace string `json:",omitempty"` // replacement, ${1} or ${name} refer to group of Match
+	Case    string `json:",omitempty"`
+
+	regexp *regexp.Regexp
+}
+
+// mappingFile is xmlNameMapping.json or nameSubstitution.json. Plain file is json object of names,
+// extended file is json object with Rules list, its names are in Prefixes or Substitutions.
+type mappingFile struct {
+	Prefixes      map[string]string
+	Substitutions map[string]string
+	Rules         []MappingRule
+	Case          string // default case strategy of name substitution
+}
+
+// readMappingFile read plain or extended mapping file, names is Prefixes or Substitutions telling which file it is.
+func readMappingFile(reader io.Reader, name string, names string) (*mappingFile, error) {
+	data, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, fatalError("mapping", name, 0, "%v", err)
+	}
+	var top map[string]json.RawMessage
+	if err := json.Unmarshal(data, &top); err != nil {
+		return nil, fatalError("mapping", name, jsonErrorLine(data, err), "invalid json: %v", err)
+	}
+	if rules, ok := top["Rules"]; !ok || !bytes.HasPrefix(bytes.TrimSpace(rules), []byte("[")) {
+		plain, err := readStringMap(bytes.NewReader(data), name)
+		if err != nil {
+			return nil, err
+		}
+		if names == "Prefixes" {
+			return &mappingFile{Prefixes: plain}, nil
+		}
+		return &mappingFile{Substitutions: plain}, nil
+	}
+	file := &mappingFile{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(file); err != nil {
+		return nil, fatalError("mapping", name, jsonErrorLine(data, err), "invalid extended mapping: %v", err)
+	}
+	prefixFile := names == "Prefixes"
+	if prefixFile && (file.Substitutions != nil || file.Case != "") {
+		return nil, fatalError("mapping", name, 0, "prefix mapping can't have Substitutions or Case")
+	}
+	if !prefixFile && file.Prefixes != nil {
+		return nil, fatalError("mapping", name, 0, "name substitution can't have Prefixes")
+	}
+	if !validCase(file.Case) {
+		return nil, fatalError("mapping", name, 0, "unknown case %q, expect lowerFirst, camelCase, snake or keep", file.Case)
+	}
+	for i := range file.Rules {
+		if err := file.Rules[i].compile(prefixFile); err != nil {
+			return nil, fatalError("mapping", name, 0, "rule %d: %v", i+1, err)
+		}
+	}
+	return file, nil
+}
+
+func validCase(strategy string) bool {
+	switch strategy {
+	case "", CaseLowerFirst, CaseCamel, CaseSnake, CaseKeep:
+		return true
+	}
+	return false
+}
+
+// compile check rule and compile its regular expression, anchored at start of FromKey or around whole segment.
+func (r *MappingRule) compile(prefix bool) error {
+	if prefix {
+		if r.Match == "" || r.Replace == "" || r.Segment != "" || r.Under != "" || r.Case != "" {
+			return errors.New("rule of prefix mapping needs Match and Replace only")
+		}
+	} else {
+		if r.Segment != "" && r.Match != "" {
+			return errors.New("rule can't have both Segment and Match")
+		}
+		if r.Replace == "" && r.Case == "" {
+			return errors.New("rule needs Replace or Case")
+		}
+		if r.Replace != "" && r.Segment == "" && r.Match == "" {
+			return errors.New("Replace needs Segment or Match")
+		}
+		if !validCase(r.Case) {
+			return fmt.Errorf("unknown case %q, expect lowerFirst, camelCase, snake or keep", r.Case)
+		}
+	}
+	if r.Match == "" {
+		return nil
+	}
+	expr := "^(?:" + r.Match + ")"
+	if !prefix {
+		expr += "$"
+	}
+	var err error
+	if r.regexp, err = regexp.Compile(expr); err != nil {
+		return fmt.Errorf("invalid Match: %v", err)
+	}
+	return nil
+}
+
+// matchRule find the first prefix rule matching start of fromKey at segment boundary, return its index,
+// length of matched prefix and ToKey prefix.
+func (m *NameMapping) matchRule(fromKey string) (int, int, string) {
+	for i, rule := range m.PrefixRules {
+		match := rule.regexp.FindStringSubmatchIndex(fromKey)
+		if match == nil || !matchPrefix(fromKey, fromKey[:match[1]]) {
+			continue
+		}
+		return i, match[1], string(rule.regexp.ExpandString(nil, rule.Replace, fromKey, match))
+	}
+	return -1, 0, ""
+}
+
+// substitute map segment of FromKey, path is FromKey up to and including segment.
+// The first matching rule wins, then literal substitution, then default case strategy.
+func (m *NameMapping) substitute(segment, path string) string {
+	for _, rule := range m.SubstitutionRules {
+		if segment == "" || rule.Under != "" && !matchPrefix(path, rule.Under) {
+			continue
+		}
+		result := segment
+		switch {
+		case rule.Segment != "":
+			if segment != rule.Segment {
+				continue
+			}
+			if rule.Replace != "" {
+				result = rule.Replace
+			}
+		case rule.regexp != nil:
+			match := rule.regexp.FindStringSubmatchIndex(segment)
+			if match == nil {
+				continue
+			}
+			if rule.Replace != "" {
+				result = string(rule.regexp.ExpandString(nil, rule.Replace, segment, match))
+			}
+		}
+		if rule.Case == "" {
+			return result
+		}
+		return convertCase(result, rule.Case)
+	}
+	if newValue, ok := m.Substitutions[segment]; ok {
+		return newValue
+	}
+	return convertCase(segment, m.Case)
+}
+
+func lowerFirst(str string) string {
+	if len(str) > 0 {
+		return strings.ToLower(str[:1]) + str[1:]
+	}
+	return str
+}
+
+func upperFirst(str string) string {
+	if len(str) > 0 {
+		return strings.ToUpper(str[:1]) + str[1:]
+	}
+	return str
+}
+
+// convertCase convert segment by case strategy, such as Net_Tax_22 to net_Tax_22 (lowerFirst), netTax22 (camelCase) or net_tax_22 (snake).
+func convertCase(str string, strategy string) string {
+	switch strategy {
+	case CaseKeep:
+		return str
+	case CaseCamel:
+		words := strings.Split(str, "_")
+		for i, word := range words {
+			if i == 0 {
+				words[i] = lowerFirst(word)
+			} else {
+				words[i] = upperFirst(word)
+			}
+		}
+		return strings.Join(words, "")
+	case CaseSnake:
+		var result strings.Builder
+		runes := []rune(str)
+		for i, r := range runes {
+			// new word start at upper case letter after lower case letter or digit, or at last upper case letter of acronym
+			if i > 0 && unicode.IsUpper(r) && runes[i-1] != '_' &&
+				(unicode.IsLower(runes[i-1]) || unicode.IsDigit(runes[i-1]) || i+1 < len(runes) && unicode.IsLower(runes[i+1])) {
+				result.WriteByte('_')
+			}
+			result.WriteRune(unicode.ToLower(r))
+		}
+		return result.String()
+	}
+	return strings.Join(stringArrayMap(strings.Split(str, "_"), lowerFirst), "_")
+}
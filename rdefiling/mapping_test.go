@@ -0,0 +1,94 @@
+package rdefiling
+
+import (
+	"strings"
+	"testing"
+)
+
+var applyMappingTests = []struct {
+	name     string
+	mapping  string
+	fromKeys []string
+	toKeys   []string // "" is field left unmapped
+	warnings []string
+}{
+	{
+		name:     "prefix stops mid-segment",
+		mapping:  `{"TaxPayer": "taxPayer"}`,
+		fromKeys: []string{"TaxPayer", "TaxPayer.Name", "TaxPayerInfo", "TaxPayerInfo.Name"},
+		toKeys:   []string{"taxPayer", "taxPayer.name", "", ""},
+	},
+	{
+		name:     "prefix with trailing dot",
+		mapping:  `{"TaxPayer.": "taxPayer."}`,
+		fromKeys: []string{"TaxPayer.Name", "TaxPayerInfo.Name"},
+		toKeys:   []string{"taxPayer.name", ""},
+	},
+	{
+		name:     "both prefixes of mid-segment pair",
+		mapping:  `{"TaxPayer": "taxPayer", "TaxPayerInfo": "payerInfo"}`,
+		fromKeys: []string{"TaxPayer.Name", "TaxPayerInfo.Name"},
+		toKeys:   []string{"taxPayer.name", "payerInfo.name"},
+	},
+	{
+		name:     "overlapping prefixes use the longest",
+		mapping:  `{"TaxPayer": "taxPayer", "TaxPayer.Address": "address", "TaxPayer.Address.Detail": "addressLines"}`,
+		fromKeys: []string{"TaxPayer.Name", "TaxPayer.Address.PostCode", "TaxPayer.Address.Detail.Line", "TaxPayer.AddressNo"},
+		toKeys:   []string{"taxPayer.name", "address.postCode", "addressLines.line", "taxPayer.addressNo"},
+	},
+	{
+		name:     "prefixes naming the same path",
+		mapping:  `{"TaxPayer": "taxPayer", "TaxPayer.": "payer."}`,
+		fromKeys: []string{"TaxPayer.Name", "TaxPayer.Id13"},
+		toKeys:   []string{"payer.name", "payer.id13"},
+		warnings: []string{`mapping "TaxPayer" and "TaxPayer." name the same path, "TaxPayer." is used`, `mapping "TaxPayer" is not used by any field`},
+	},
+	{
+		name:     "prefix rule stops mid-segment",
+		mapping:  `{"Prefixes": {"TaxPayer": "taxPayer"}, "Rules": [{"Match": "Tax(Payer|Form)", "Replace": "${1}Rule"}]}`,
+		fromKeys: []string{"TaxPayerInfo.Name", "TaxForm.Total"},
+		toKeys:   []string{"", "FormRule.total"},
+		warnings: []string{`mapping "TaxPayer" is not used by any field`},
+	},
+	{
+		name:     "prefix rule longer than literal prefix",
+		mapping:  `{"Prefixes": {"TaxForm": "taxForm"}, "Rules": [{"Match": "TaxForm\\.(Detail)", "Replace": "details"}]}`,
+		fromKeys: []string{"TaxForm.Total", "TaxForm.Detail.Amount"},
+		toKeys:   []string{"taxForm.total", "details.amount"},
+	},
+	{
+		name:     "unused entries",
+		mapping:  `{"Prefixes": {"TaxPayer": "taxPayer", "Sender": "sender"}, "Rules": [{"Match": "Auditor", "Replace": "auditor"}]}`,
+		fromKeys: []string{"TaxPayer.Name", "TaxPayerInfo.Name"},
+		toKeys:   []string{"taxPayer.name", ""},
+		warnings: []string{`mapping "Sender" is not used by any field`, `mapping rule 1 "Auditor" is not used by any field`},
+	},
+}
+
+func TestApplyMapping(t *testing.T) {
+	for _, test := range applyMappingTests {
+		t.Run(test.name, func(t *testing.T) {
+			m, err := ParseNameMapping(strings.NewReader(test.mapping), "xmlNameMapping.json", strings.NewReader(`{}`), "nameSubstitution.json")
+			if err != nil {
+				t.Fatal(err)
+			}
+			spec := &Spec{File: "spec.csv"}
+			for _, fromKey := range test.fromKeys {
+				spec.Fields = append(spec.Fields, Field{FromKey: fromKey, Type: "String"})
+			}
+			spec.ApplyMapping(m)
+			for i, field := range spec.Fields {
+				if field.ToKey != test.toKeys[i] {
+					t.Errorf("%s mapped to %q, want %q", field.FromKey, field.ToKey, test.toKeys[i])
+				}
+			}
+			var warnings []string
+			for _, d := range spec.Diagnostics {
+				warnings = append(warnings, d.Message)
+			}
+			if strings.Join(warnings, "\n") != strings.Join(test.warnings, "\n") {
+				t.Errorf("warnings\n%s\nwant\n%s", strings.Join(warnings, "\n"), strings.Join(test.warnings, "\n"))
+			}
+		})
+	}
+}
@@ -0,0 +1,208 @@
+package rdefiling
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Overrides is exceptions of a form which java parser, json and xml conversion and test data follow instead of spec.
+type Overrides struct {
+	Exclude    []string               `json:",omitempty"` // FromKey prefixes left out of java parser and json conversion, handled by hand
+	JSONTypes  map[string]string      `json:",omitempty"` // type of value in frontend json by FromKey, such as String for number kept as text
+	ForceArray []string               `json:",omitempty"` // FromKey of objects handled as array
+	Static     map[string]interface{} `json:",omitempty"` // value always written into xml by FromKey, json is not looked up
+	Defaults   map[string]interface{} `json:",omitempty"` // value written into xml by FromKey when json has none
+}
+
+// ReadOverrides read overrides json, name is used in diagnostics.
+func ReadOverrides(reader io.Reader, name string) (*Overrides, error) {
+	data, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, fatalError("overrides", name, 0, "%v", err)
+	}
+	overrides := &Overrides{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(overrides); err != nil {
+		return nil, fatalError("overrides", name, jsonErrorLine(data, err), "invalid overrides: %v", err)
+	}
+	// JSONTypes are taken as written, spec type is never guessed from element name for them
+	for key, typ := range overrides.JSONTypes {
+		typ = normalizeTypeSpelling(typ)
+		overrides.JSONTypes[key] = typ
+		if !validSpecType(typ) || typ == "Object" || typ == "Array" {
+			return nil, fatalError("overrides", name, 0, "type of %s: unknown type %q", key, typ)
+		}
+	}
+	for _, values := range []map[string]interface{}{overrides.Static, overrides.Defaults} {
+		for key, value := range values {
+			switch value.(type) {
+			case string, float64, bool:
+			default:
+				return nil, fatalError("overrides", name, 0, "value of %s must be string, number or boolean", key)
+			}
+		}
+	}
+	return overrides, nil
+}
+
+// ReadOverridesFile read overrides json file.
+func ReadOverridesFile(path string) (*Overrides, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fatalError("overrides", path, 0, "%v", err)
+	}
+	defer file.Close()
+	return ReadOverrides(file, path)
+}
+
+// ApplyOverrides keep overrides for generators and mark forced arrays. Forced array must be object,
+// entry matching no field is warned.
+func (s *Spec) ApplyOverrides(o *Overrides) {
+	s.Overrides = o
+	used := map[string]bool{}
+	for i := range s.Fields {
+		field := &s.Fields[i]
+		for _, prefix := range o.Exclude {
+			if matchPrefix(field.FromKey, prefix) {
+				used[prefix] = true
+			}
+		}
+		if stringInSlice(field.FromKey, o.ForceArray) {
+			used[field.FromKey] = true
+			if field.Type != "Object" && field.Type != "Array" {
+				s.errorf("overrides", field, "can't force %s field to array, only object", field.Type)
+				continue
+			}
+			s.ForceArray[field.FromKey] = true
+		}
+		for _, values := range []map[string]interface{}{o.Static, o.Defaults} {
+			if value, ok := values[field.FromKey]; ok {
+				used[field.FromKey] = true
+				if field.Type == "Object" || field.Type == "Array" {
+					s.errorf("overrides", field, "%s field can't have value %v", field.Type, value)
+				} else if message := s.checkOverrideValue(field, value); message != "" {
+					s.errorf("overrides", field, "%s", message)
+				}
+			}
+		}
+		if _, ok := o.JSONTypes[field.FromKey]; ok {
+			used[field.FromKey] = true
+		}
+	}
+	var unused []string
+	for _, key := range o.Exclude {
+		if !used[key] {
+			unused = append(unused, key)
+		}
+	}
+	for _, key := range o.ForceArray {
+		if !used[key] {
+			unused = append(unused, key)
+		}
+	}
+	for _, keys := range []map[string]interface{}{o.Static, o.Defaults} {
+		for key := range keys {
+			if !used[key] {
+				unused = append(unused, key)
+			}
+		}
+	}
+	for key := range o.JSONTypes {
+		if !used[key] {
+			unused = append(unused, key)
+		}
+	}
+	sort.Strings(unused)
+	if len(unused) > 0 {
+		s.warnf("overrides", nil, "override match no field: %s", strings.Join(unused, ", "))
+	}
+}
+
+// checkOverrideValue check static or default value against field, return message of problem or "".
+// Kind of json value must suit type of field in json, as value of frontend json does, and its xml text must be
+// valid for type of field in xml.
+func (s *Spec) checkOverrideValue(field *Field, value interface{}) string {
+	typ := s.jsonType(field)
+	numeric := typ == "Number" || typ == "Year" || strings.HasPrefix(typ, "Decimal")
+	switch value.(type) {
+	case float64:
+		if !numeric {
+			return "number " + overrideText(value) + " is not allowed for type " + typ
+		}
+	case bool:
+		if typ != "Boolean" {
+			return "boolean " + overrideText(value) + " is not allowed for type " + typ
+		}
+	case string:
+		if numeric || typ == "Boolean" {
+			return "string " + strconv.Quote(overrideText(value)) + " is not allowed for type " + typ + ", write it without quotes"
+		}
+	}
+	return checkSimpleValue(field, overrideText(value))
+}
+
+// excluded report whether field is left out of java parser and json conversion.
+func (s *Spec) excluded(fromKey string) bool {
+	if s.Overrides == nil {
+		return false
+	}
+	for _, prefix := range s.Overrides.Exclude {
+		if matchPrefix(fromKey, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// static report whether field always get static value in xml, so it is not read from json.
+func (s *Spec) static(fromKey string) bool {
+	if s.Overrides == nil {
+		return false
+	}
+	_, ok := s.Overrides.Static[fromKey]
+	return ok
+}
+
+// jsonType return type of value of field in frontend json, Type of field unless it is overridden.
+func (s *Spec) jsonType(field *Field) string {
+	if s.Overrides != nil {
+		if typ, ok := s.Overrides.JSONTypes[field.FromKey]; ok {
+			return typ
+		}
+	}
+	return field.Type
+}
+
+// xmlValue return value of field written into xml, static value replace value of json and default fill missing one.
+func (s *Spec) xmlValue(fromKey string, value interface{}) interface{} {
+	if s.Overrides == nil {
+		return value
+	}
+	if static, ok := s.Overrides.Static[fromKey]; ok {
+		return static
+	}
+	if value == nil {
+		return s.Overrides.Defaults[fromKey]
+	}
+	return value
+}
+
+// overrideText format static or default value as xml text.
+func overrideText(value interface{}) string {
+	switch v := value.(type) {
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	case string:
+		return v
+	}
+	return ""
+}
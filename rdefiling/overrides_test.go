@@ -0,0 +1,161 @@
+package rdefiling
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+var overridesTestFields = []Field{
+	{FromKey: "TaxForm", Type: "Object"},
+	{FromKey: "TaxForm.Total", ToKey: "total", Type: "Decimal(15,2)"},
+	{FromKey: "TaxForm.FilingNo", ToKey: "filingNo", Type: "Number", MaxLength: "2"},
+	{FromKey: "TaxForm.Version", ToKey: "version", Type: "String"},
+	{FromKey: "TaxForm.Currency", ToKey: "currency", Type: "String", MaxLength: "3"},
+	{FromKey: "TaxForm.Note", ToKey: "note", Type: "String"},
+	{FromKey: "TaxForm.Note.Text", ToKey: "note.text", Type: "String"},
+	{FromKey: "TaxForm.Detail", ToKey: "details", Type: "Object"},
+	{FromKey: "TaxForm.Detail.Amount", ToKey: "details.amount", Type: "Decimal(15,2)"},
+	{FromKey: "TaxForm.Paid", ToKey: "paid", Type: "Boolean"},
+	{FromKey: "TaxForm.DueDate", ToKey: "dueDate", Type: "Date"},
+}
+
+// overridesTestSpec return spec of overridesTestFields with overrides applied.
+func overridesTestSpec(o *Overrides) *Spec {
+	spec := &Spec{File: "spec.csv", Fields: append([]Field(nil), overridesTestFields...), ForceArray: map[string]bool{}}
+	spec.ApplyOverrides(o)
+	return spec
+}
+
+func TestApplyOverrides(t *testing.T) {
+	tests := []struct {
+		name      string
+		overrides string
+		want      []string // messages of diagnostics
+	}{
+		{"valid values", `{"Static": {"TaxForm.Total": 10.5, "TaxForm.Paid": true, "TaxForm.DueDate": "2021-05-31"},
+			"Defaults": {"TaxForm.FilingNo": 0, "TaxForm.Currency": "THB"}}`, nil},
+		{"string on decimal", `{"Static": {"TaxForm.Total": "10.50"}}`,
+			[]string{`string "10.50" is not allowed for type Decimal(15,2), write it without quotes`}},
+		{"string on number kept as text in json", `{"JSONTypes": {"TaxForm.FilingNo": "String"}, "Defaults": {"TaxForm.FilingNo": "07"}}`, nil},
+		{"number on string", `{"Defaults": {"TaxForm.Currency": 764}}`, []string{"number 764 is not allowed for type String"}},
+		{"boolean on number", `{"Static": {"TaxForm.FilingNo": true}}`, []string{"boolean true is not allowed for type Number"}},
+		{"string on boolean", `{"Static": {"TaxForm.Paid": "true"}}`,
+			[]string{`string "true" is not allowed for type Boolean, write it without quotes`}},
+		{"too many fraction digits", `{"Static": {"TaxForm.Total": 10.555}}`, []string{"value 10.555 exceeds fractionDigits 2"}},
+		{"fraction on number", `{"Defaults": {"TaxForm.FilingNo": 1.5}}`, []string{`value "1.5" is not xs:integer`}},
+		{"too long string", `{"Defaults": {"TaxForm.Currency": "Baht"}}`, []string{"length 4 exceeds maxLength 3"}},
+		{"invalid date", `{"Static": {"TaxForm.DueDate": "2021-02-30"}}`, []string{`value "2021-02-30" is not valid date`}},
+		{"value on object", `{"Static": {"TaxForm": "x"}}`, []string{"Object field can't have value x"}},
+		{"force array of object", `{"ForceArray": ["TaxForm.Detail"]}`, nil},
+		{"force array of non-object", `{"ForceArray": ["TaxForm.Total"]}`, []string{"can't force Decimal(15,2) field to array, only object"}},
+		{"unused entries", `{"Exclude": ["TaxForm.Not"], "ForceArray": ["TaxForm.Details"], "JSONTypes": {"TaxForm.Sum": "String"}, "Static": {"TaxForm.Ver": "1"}}`,
+			[]string{"override match no field: TaxForm.Details, TaxForm.Not, TaxForm.Sum, TaxForm.Ver"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			o, err := ReadOverrides(strings.NewReader(test.overrides), "overrides.json")
+			if err != nil {
+				t.Fatal(err)
+			}
+			spec := overridesTestSpec(o)
+			var messages []string
+			for _, d := range spec.Diagnostics {
+				messages = append(messages, d.Message)
+			}
+			if strings.Join(messages, "\n") != strings.Join(test.want, "\n") {
+				t.Errorf("diagnostics\n%s\nwant\n%s", strings.Join(messages, "\n"), strings.Join(test.want, "\n"))
+			}
+		})
+	}
+}
+
+func TestReadOverridesValueKind(t *testing.T) {
+	if _, err := ReadOverrides(strings.NewReader(`{"Static": {"TaxForm.Total": [1]}}`), "overrides.json"); err == nil ||
+		!strings.Contains(err.Error(), "value of TaxForm.Total must be string, number or boolean") {
+		t.Errorf("error %v, want value kind error", err)
+	}
+	if _, err := ReadOverrides(strings.NewReader(`{"JSONTypes": {"TaxForm.Detail": "Array"}}`), "overrides.json"); err == nil ||
+		!strings.Contains(err.Error(), `unknown type "Array"`) {
+		t.Errorf("error %v, want unknown type error", err)
+	}
+}
+
+const overridesTestOverrides = `{
+	"Exclude": ["TaxForm.Note"],
+	"JSONTypes": {"TaxForm.FilingNo": "String"},
+	"ForceArray": ["TaxForm.Detail"],
+	"Static": {"TaxForm.Version": "3.0.0"},
+	"Defaults": {"TaxForm.Currency": "THB"}
+}`
+
+func TestOverridesJSONToXML(t *testing.T) {
+	o, err := ReadOverrides(strings.NewReader(overridesTestOverrides), "overrides.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	spec := overridesTestSpec(o)
+	input := `{"total": 10, "filingNo": "07", "version": "9.9.9", "note": {"text": "by hand"},
+		"details": [{"amount": 1}, {"amount": 2.5}]}`
+	var output bytes.Buffer
+	if err := spec.JSONToXML(strings.NewReader(input), "test.json", &output); err != nil {
+		t.Fatal(err)
+	}
+	want := `<rd:RdForm xmlns:rd="urn:schemas-rd-go-th:xml-services:common">
+    <rd:TaxForm>
+        <rd:Total>10.00</rd:Total>
+        <rd:FilingNo>07</rd:FilingNo>
+        <rd:Version>3.0.0</rd:Version>
+        <rd:Currency>THB</rd:Currency>
+        <rd:Detail>
+            <rd:Amount>1.00</rd:Amount>
+        </rd:Detail>
+        <rd:Detail>
+            <rd:Amount>2.50</rd:Amount>
+        </rd:Detail>
+    </rd:TaxForm>
+</rd:RdForm>`
+	if output.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", output.String(), want)
+	}
+	if len(spec.Diagnostics) != 0 {
+		t.Errorf("unexpected diagnostics %v", spec.Diagnostics)
+	}
+
+	// value in json replace default, forced array must be array in json
+	output.Reset()
+	input = `{"currency": "USD", "details": {"amount": 1}}`
+	if err := spec.JSONToXML(strings.NewReader(input), "test.json", &output); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(output.String(), "<rd:Currency>USD</rd:Currency>") {
+		t.Errorf("default replaced json value: %s", output.String())
+	}
+	if !spec.Diagnostics.HasError() || !strings.Contains(spec.Diagnostics[0].Message, "expect array at details") {
+		t.Errorf("diagnostics %v, want array error", spec.Diagnostics)
+	}
+}
+
+func TestOverridesXMLToJSON(t *testing.T) {
+	o, err := ReadOverrides(strings.NewReader(overridesTestOverrides), "overrides.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	spec := overridesTestSpec(o)
+	document := `<rd:RdForm xmlns:rd="urn:schemas-rd-go-th:xml-services:common"><rd:TaxForm>` +
+		`<rd:Total>10.00</rd:Total><rd:FilingNo>07</rd:FilingNo><rd:Version>3.0.0</rd:Version>` +
+		`<rd:Note><rd:Text>by hand</rd:Text></rd:Note><rd:Detail><rd:Amount>1.00</rd:Amount></rd:Detail>` +
+		`</rd:TaxForm></rd:RdForm>`
+	var output bytes.Buffer
+	if err := spec.XMLToJSON(strings.NewReader(document), "test.xml", nil, "", &output); err != nil {
+		t.Fatal(err)
+	}
+	// static version and excluded note are left out, filing number stay text, single detail is still array
+	want := "{\n\t\"details\": [\n\t\t{\n\t\t\t\"amount\": 1.00\n\t\t}\n\t],\n\t\"filingNo\": \"07\",\n\t\"total\": 10.00\n}\n"
+	if output.String() != want {
+		t.Errorf("got %s, want %s", output.String(), want)
+	}
+	if len(spec.Diagnostics) != 0 {
+		t.Errorf("unexpected diagnostics %v", spec.Diagnostics)
+	}
+}
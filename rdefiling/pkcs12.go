@@ -0,0 +1,401 @@
+package rdefiling
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/des"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"hash"
+	"math/big"
+	"unicode/utf16"
+)
+
+// PKCS#12 reader supports files exported by OpenSSL 3 and Java keytool (PBES2 with AES) and key encrypted with 3DES.
+// Certificates encrypted with legacy RC2 are not supported.
+
+var (
+	oidDataContent          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}
+	oidEncryptedDataContent = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 6}
+	oidKeyBag               = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 10, 1, 1}
+	oidShroudedKeyBag       = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 10, 1, 2}
+	oidCertBag              = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 10, 1, 3}
+	oidX509Certificate      = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 22, 1}
+	oidPBEWithSHAAnd3DES    = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 1, 3}
+	oidPBEWithSHAAnd128RC2  = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 1, 5}
+	oidPBEWithSHAAnd40RC2   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 1, 6}
+	oidPBES2                = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 13}
+	oidPBKDF2               = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 12}
+	oidHMACWithSHA1         = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 7}
+	oidHMACWithSHA256       = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 9}
+	oidHMACWithSHA512       = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 11}
+	oidAES128CBC            = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 2}
+	oidAES192CBC            = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 22}
+	oidAES256CBC            = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 42}
+	oidDESEDE3CBC           = asn1.ObjectIdentifier{1, 2, 840, 113549, 3, 7}
+	oidSHA1                 = asn1.ObjectIdentifier{1, 3, 14, 3, 2, 26}
+	oidSHA256               = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+	oidSHA512               = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 3}
+)
+
+var errWrongPassword = errors.New("wrong password or corrupted key")
+
+type pkcs12PFX struct {
+	Version  int
+	AuthSafe pkcs12ContentInfo
+	MacData  pkcs12MacData `asn1:"optional"`
+}
+
+type pkcs12ContentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"tag:0,explicit,optional"`
+}
+
+type pkcs12MacData struct {
+	Mac        pkcs12DigestInfo
+	MacSalt    []byte
+	Iterations int `asn1:"optional,default:1"`
+}
+
+type pkcs12DigestInfo struct {
+	Algorithm pkix.AlgorithmIdentifier
+	Digest    []byte
+}
+
+type pkcs12EncryptedData struct {
+	Version              int
+	EncryptedContentInfo pkcs12EncryptedContentInfo
+}
+
+type pkcs12EncryptedContentInfo struct {
+	ContentType                asn1.ObjectIdentifier
+	ContentEncryptionAlgorithm pkix.AlgorithmIdentifier
+	EncryptedContent           []byte `asn1:"tag:0,optional"`
+}
+
+type pkcs12SafeBag struct {
+	ID         asn1.ObjectIdentifier
+	Value      asn1.RawValue `asn1:"tag:0,explicit"`
+	Attributes asn1.RawValue `asn1:"optional"`
+}
+
+type pkcs12CertBag struct {
+	ID   asn1.ObjectIdentifier
+	Data []byte `asn1:"tag:0,explicit"`
+}
+
+type encryptedPrivateKeyInfo struct {
+	Algorithm     pkix.AlgorithmIdentifier
+	EncryptedData []byte
+}
+
+type pbeParams struct {
+	Salt       []byte
+	Iterations int
+}
+
+type pbes2Params struct {
+	KeyDerivationFunc pkix.AlgorithmIdentifier
+	EncryptionScheme  pkix.AlgorithmIdentifier
+}
+
+type pbkdf2Params struct {
+	Salt       []byte
+	Iterations int
+	KeyLength  int                      `asn1:"optional"`
+	PRF        pkix.AlgorithmIdentifier `asn1:"optional"`
+}
+
+// parsePKCS12 decode private key and certificates of PKCS#12 file, password is checked by MAC when file has one.
+func parsePKCS12(data []byte, password string) (interface{}, []*x509.Certificate, error) {
+	var pfx pkcs12PFX
+	if rest, err := asn1.Unmarshal(data, &pfx); err != nil {
+		return nil, nil, fmt.Errorf("not PKCS#12 file: %v", err)
+	} else if len(rest) != 0 {
+		return nil, nil, errors.New("not PKCS#12 file: trailing data")
+	}
+	if !pfx.AuthSafe.ContentType.Equal(oidDataContent) {
+		return nil, nil, errors.New("PKCS#12 signed with public key is not supported")
+	}
+	var authSafe []byte
+	if _, err := asn1.Unmarshal(pfx.AuthSafe.Content.Bytes, &authSafe); err != nil {
+		return nil, nil, fmt.Errorf("PKCS#12 content: %v", err)
+	}
+	if pfx.MacData.Mac.Algorithm.Algorithm != nil {
+		if err := pfx.MacData.verify(authSafe, password); err != nil {
+			return nil, nil, err
+		}
+	}
+	var contents []pkcs12ContentInfo
+	if _, err := asn1.Unmarshal(authSafe, &contents); err != nil {
+		return nil, nil, fmt.Errorf("PKCS#12 content: %v", err)
+	}
+	var key interface{}
+	var certificates []*x509.Certificate
+	for _, content := range contents {
+		var safeContents []byte
+		switch {
+		case content.ContentType.Equal(oidDataContent):
+			if _, err := asn1.Unmarshal(content.Content.Bytes, &safeContents); err != nil {
+				return nil, nil, fmt.Errorf("PKCS#12 content: %v", err)
+			}
+		case content.ContentType.Equal(oidEncryptedDataContent):
+			var encrypted pkcs12EncryptedData
+			if _, err := asn1.Unmarshal(content.Content.Bytes, &encrypted); err != nil {
+				return nil, nil, fmt.Errorf("PKCS#12 encrypted content: %v", err)
+			}
+			var err error
+			info := encrypted.EncryptedContentInfo
+			if safeContents, err = decryptPBE(info.ContentEncryptionAlgorithm, info.EncryptedContent, password); err != nil {
+				return nil, nil, err
+			}
+		default:
+			return nil, nil, fmt.Errorf("PKCS#12 content type %v is not supported", content.ContentType)
+		}
+		var bags []pkcs12SafeBag
+		if _, err := asn1.Unmarshal(safeContents, &bags); err != nil {
+			return nil, nil, fmt.Errorf("PKCS#12 safe contents: %v", err)
+		}
+		for _, bag := range bags {
+			switch {
+			case bag.ID.Equal(oidCertBag):
+				var certBag pkcs12CertBag
+				if _, err := asn1.Unmarshal(bag.Value.Bytes, &certBag); err != nil {
+					return nil, nil, fmt.Errorf("PKCS#12 certificate: %v", err)
+				}
+				if !certBag.ID.Equal(oidX509Certificate) {
+					continue
+				}
+				certificate, err := x509.ParseCertificate(certBag.Data)
+				if err != nil {
+					return nil, nil, err
+				}
+				certificates = append(certificates, certificate)
+			case bag.ID.Equal(oidKeyBag), bag.ID.Equal(oidShroudedKeyBag):
+				if key != nil {
+					return nil, nil, errors.New("PKCS#12 file contains more than one private key")
+				}
+				der := bag.Value.Bytes
+				if bag.ID.Equal(oidShroudedKeyBag) {
+					var err error
+					if der, err = decryptPKCS8(der, password); err != nil {
+						return nil, nil, err
+					}
+				}
+				var err error
+				if key, err = x509.ParsePKCS8PrivateKey(der); err != nil {
+					return nil, nil, err
+				}
+			}
+		}
+	}
+	return key, certificates, nil
+}
+
+func (m *pkcs12MacData) verify(content []byte, password string) error {
+	newHash, size, block := pkcs12Hash(m.Mac.Algorithm.Algorithm)
+	if newHash == nil {
+		return fmt.Errorf("PKCS#12 MAC algorithm %v is not supported", m.Mac.Algorithm.Algorithm)
+	}
+	passwords := [][]byte{bmpString(password)}
+	if password == "" {
+		passwords = append(passwords, nil) // some tools use no password at all instead of empty BMPString
+	}
+	for _, p := range passwords {
+		mac := hmac.New(newHash, pkcs12KDF(newHash, size, block, p, m.MacSalt, 3, m.Iterations, size))
+		mac.Write(content)
+		if hmac.Equal(mac.Sum(nil), m.Mac.Digest) {
+			return nil
+		}
+	}
+	return errWrongPassword
+}
+
+// decryptPKCS8 decrypt EncryptedPrivateKeyInfo into PKCS#8 PrivateKeyInfo.
+func decryptPKCS8(der []byte, password string) ([]byte, error) {
+	var info encryptedPrivateKeyInfo
+	if _, err := asn1.Unmarshal(der, &info); err != nil {
+		return nil, fmt.Errorf("encrypted private key: %v", err)
+	}
+	return decryptPBE(info.Algorithm, info.EncryptedData, password)
+}
+
+// decryptPBE decrypt data with password based encryption of PKCS#12 or PKCS#5 PBES2.
+func decryptPBE(algorithm pkix.AlgorithmIdentifier, data []byte, password string) ([]byte, error) {
+	var block cipher.Block
+	var iv []byte
+	switch {
+	case algorithm.Algorithm.Equal(oidPBEWithSHAAnd3DES):
+		var params pbeParams
+		if _, err := asn1.Unmarshal(algorithm.Parameters.FullBytes, &params); err != nil {
+			return nil, fmt.Errorf("PBE parameters: %v", err)
+		}
+		key := pkcs12KDF(sha1.New, sha1.Size, 64, bmpString(password), params.Salt, 1, params.Iterations, 24)
+		iv = pkcs12KDF(sha1.New, sha1.Size, 64, bmpString(password), params.Salt, 2, params.Iterations, 8)
+		var err error
+		if block, err = des.NewTripleDESCipher(key); err != nil {
+			return nil, err
+		}
+	case algorithm.Algorithm.Equal(oidPBES2):
+		var params pbes2Params
+		if _, err := asn1.Unmarshal(algorithm.Parameters.FullBytes, &params); err != nil {
+			return nil, fmt.Errorf("PBES2 parameters: %v", err)
+		}
+		if !params.KeyDerivationFunc.Algorithm.Equal(oidPBKDF2) {
+			return nil, fmt.Errorf("PBES2 key derivation %v is not supported", params.KeyDerivationFunc.Algorithm)
+		}
+		var kdf pbkdf2Params
+		if _, err := asn1.Unmarshal(params.KeyDerivationFunc.Parameters.FullBytes, &kdf); err != nil {
+			return nil, fmt.Errorf("PBKDF2 parameters: %v", err)
+		}
+		var prf func() hash.Hash
+		switch {
+		case kdf.PRF.Algorithm == nil, kdf.PRF.Algorithm.Equal(oidHMACWithSHA1):
+			prf = sha1.New
+		case kdf.PRF.Algorithm.Equal(oidHMACWithSHA256):
+			prf = sha256.New
+		case kdf.PRF.Algorithm.Equal(oidHMACWithSHA512):
+			prf = sha512.New
+		default:
+			return nil, fmt.Errorf("PBKDF2 function %v is not supported", kdf.PRF.Algorithm)
+		}
+		var keySize int
+		scheme := params.EncryptionScheme.Algorithm
+		switch {
+		case scheme.Equal(oidAES128CBC):
+			keySize = 16
+		case scheme.Equal(oidAES192CBC):
+			keySize = 24
+		case scheme.Equal(oidAES256CBC):
+			keySize = 32
+		case scheme.Equal(oidDESEDE3CBC):
+			keySize = 24
+		default:
+			return nil, fmt.Errorf("PBES2 encryption %v is not supported", scheme)
+		}
+		if _, err := asn1.Unmarshal(params.EncryptionScheme.Parameters.FullBytes, &iv); err != nil {
+			return nil, fmt.Errorf("PBES2 iv: %v", err)
+		}
+		key := pbkdf2Key(prf, []byte(password), kdf.Salt, kdf.Iterations, keySize)
+		var err error
+		if scheme.Equal(oidDESEDE3CBC) {
+			block, err = des.NewTripleDESCipher(key)
+		} else {
+			block, err = aes.NewCipher(key)
+		}
+		if err != nil {
+			return nil, err
+		}
+	case algorithm.Algorithm.Equal(oidPBEWithSHAAnd40RC2), algorithm.Algorithm.Equal(oidPBEWithSHAAnd128RC2):
+		return nil, errors.New("RC2 encrypted PKCS#12 is not supported, export it again with -certpbe AES-256-CBC -keypbe AES-256-CBC")
+	default:
+		return nil, fmt.Errorf("encryption %v is not supported", algorithm.Algorithm)
+	}
+	if len(iv) != block.BlockSize() || len(data) == 0 || len(data)%block.BlockSize() != 0 {
+		return nil, errWrongPassword
+	}
+	result := make([]byte, len(data))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(result, data)
+	padding := int(result[len(result)-1])
+	if padding == 0 || padding > block.BlockSize() || !bytes.Equal(result[len(result)-padding:], bytes.Repeat([]byte{byte(padding)}, padding)) {
+		return nil, errWrongPassword
+	}
+	return result[:len(result)-padding], nil
+}
+
+// pkcs12Hash return hash of MAC with its size and block size used by pkcs12KDF.
+func pkcs12Hash(oid asn1.ObjectIdentifier) (func() hash.Hash, int, int) {
+	switch {
+	case oid.Equal(oidSHA1):
+		return sha1.New, sha1.Size, 64
+	case oid.Equal(oidSHA256):
+		return sha256.New, sha256.Size, 64
+	case oid.Equal(oidSHA512):
+		return sha512.New, sha512.Size, 128
+	}
+	return nil, 0, 0
+}
+
+// bmpString encode password as big endian UTF-16 with terminating zero, as PKCS#12 key derivation expect.
+func bmpString(str string) []byte {
+	var result []byte
+	for _, r := range utf16.Encode([]rune(str)) {
+		result = append(result, byte(r>>8), byte(r))
+	}
+	return append(result, 0, 0)
+}
+
+// pkcs12KDF derive key, iv (id 2) or MAC key (id 3) from password as RFC 7292 appendix B.2.
+func pkcs12KDF(newHash func() hash.Hash, u, v int, password, salt []byte, id byte, iterations, size int) []byte {
+	fill := func(data []byte) []byte {
+		if len(data) == 0 {
+			return nil
+		}
+		length := v * ((len(data) + v - 1) / v)
+		result := make([]byte, length)
+		for i := range result {
+			result[i] = data[i%len(data)]
+		}
+		return result
+	}
+	d := bytes.Repeat([]byte{id}, v)
+	i := append(fill(salt), fill(password)...)
+	one := big.NewInt(1)
+	var result []byte
+	for len(result) < size {
+		h := newHash()
+		h.Write(d)
+		h.Write(i)
+		a := h.Sum(nil)
+		for n := 1; n < iterations; n++ {
+			h.Reset()
+			h.Write(a)
+			a = h.Sum(a[:0])
+		}
+		result = append(result, a...)
+		b := new(big.Int).SetBytes(fill(a))
+		b.Add(b, one)
+		for j := 0; j < len(i); j += v {
+			block := new(big.Int).SetBytes(i[j : j+v])
+			block.Add(block, b)
+			sum := block.Bytes() // modulo 2^(8v), keep v bytes padded with leading zero
+			if len(sum) > v {
+				sum = sum[len(sum)-v:]
+			}
+			copy(i[j:j+v], make([]byte, v-len(sum)))
+			copy(i[j+v-len(sum):j+v], sum)
+		}
+	}
+	return result[:size]
+}
+
+// pbkdf2Key derive key from password as PKCS#5 PBKDF2.
+func pbkdf2Key(prf func() hash.Hash, password, salt []byte, iterations, size int) []byte {
+	mac := hmac.New(prf, password)
+	var result []byte
+	for block := uint32(1); len(result) < size; block++ {
+		mac.Reset()
+		mac.Write(salt)
+		mac.Write([]byte{byte(block >> 24), byte(block >> 16), byte(block >> 8), byte(block)})
+		u := mac.Sum(nil)
+		t := append([]byte(nil), u...)
+		for n := 1; n < iterations; n++ {
+			mac.Reset()
+			mac.Write(u)
+			u = mac.Sum(u[:0])
+			for k := range t {
+				t[k] ^= u[k]
+			}
+		}
+		result = append(result, t...)
+	}
+	return result[:size]
+}
@@ -0,0 +1,332 @@
+package rdefiling
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// RuleCheck is declarative check of rule guideline, Check is one of
+//
+//	regex      whole value must match Pattern
+//	checksum   value must pass checksum Algorithm, only thaiTaxID is supported
+//	enum       value must be one of Values, or of code list of field when Values is empty
+//	compare    value compared with value of other Field by Operator (==, !=, <, <=, >, >=)
+//	requiredIf element must present when other Field present, and has one of Values if Values is not empty
+//	sum        value must equal sum of Fields, missing field count as zero
+//
+// Check apply to every field referring to its rule, or to fields of FromKeys instead. A rule may have several
+// checks written as json array, such as one per field of a rule covering a group of fields.
+type RuleCheck struct {
+	Description string `json:",omitempty"`
+	Check       string
+	FromKeys    []string `json:",omitempty"`
+	Pattern     string   `json:",omitempty"`
+	Algorithm   string   `json:",omitempty"`
+	Values      []string `json:",omitempty"`
+	Field       string   `json:",omitempty"`
+	Operator    string   `json:",omitempty"`
+	Fields      []string `json:",omitempty"`
+
+	pattern *regexp.Regexp
+}
+
+// builtInRules are available without catalogue file, catalogue file may override them.
+var builtInRules = map[string]RuleCheck{
+	"Common_R23": {
+		Description: "เลขประจำตัวผู้เสียภาษีอากร 13 หลักต้องถูกต้องตามหลักการคำนวณ check digit",
+		Check:       "checksum",
+		Algorithm:   "thaiTaxID",
+	},
+}
+
+// RuleCatalogue map rule guideline code to its checks.
+type RuleCatalogue map[string][]*RuleCheck
+
+// BuiltInRuleCatalogue return new catalogue contains only built-in rules.
+func BuiltInRuleCatalogue() RuleCatalogue {
+	catalogue := RuleCatalogue{}
+	for id, rule := range builtInRules {
+		rule := rule
+		catalogue[id] = []*RuleCheck{&rule}
+	}
+	return catalogue
+}
+
+// ReadRuleCatalogue read catalogue file in json format, rules in file are added to built-in rules.
+func ReadRuleCatalogue(reader io.Reader, name string) (RuleCatalogue, error) {
+	data, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, fatalError("rule", name, 0, "%v", err)
+	}
+	var entries map[string]json.RawMessage
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fatalError("rule", name, jsonErrorLine(data, err), "invalid json: %v", err)
+	}
+	catalogue := BuiltInRuleCatalogue()
+	for id, entry := range entries {
+		var rules []*RuleCheck
+		var err error
+		if trimmed := bytes.TrimSpace(entry); len(trimmed) > 0 && trimmed[0] == '[' {
+			err = json.Unmarshal(entry, &rules)
+		} else {
+			rules = []*RuleCheck{nil}
+			err = json.Unmarshal(entry, &rules[0])
+		}
+		if err != nil {
+			return nil, fatalError("rule", name, 0, "rule %s is invalid: %v", id, err)
+		}
+		if len(rules) == 0 {
+			return nil, fatalError("rule", name, 0, "rule %s has no check", id)
+		}
+		for _, rule := range rules {
+			if rule == nil {
+				return nil, fatalError("rule", name, 0, "rule %s is null", id)
+			}
+			if err := rule.compile(); err != nil {
+				return nil, fatalError("rule", name, 0, "rule %s %v", id, err)
+			}
+		}
+		catalogue[id] = rules
+	}
+	return catalogue, nil
+}
+
+func (rule *RuleCheck) compile() error {
+	switch rule.Check {
+	case "regex":
+		pattern, err := compilePattern(rule.Pattern)
+		if err != nil {
+			return fmt.Errorf("has invalid pattern: %v", err)
+		}
+		rule.pattern = pattern
+	case "checksum":
+		if rule.Algorithm != "thaiTaxID" {
+			return fmt.Errorf("has unknown checksum algorithm %q", rule.Algorithm)
+		}
+	case "enum":
+	case "compare":
+		switch rule.Operator {
+		case "==", "!=", "<", "<=", ">", ">=":
+		default:
+			return fmt.Errorf("has unknown operator %q", rule.Operator)
+		}
+		if rule.Field == "" {
+			return fmt.Errorf("need Field to compare with")
+		}
+	case "requiredIf":
+		if rule.Field == "" {
+			return fmt.Errorf("need Field as condition")
+		}
+	case "sum":
+		if len(rule.Fields) == 0 {
+			return fmt.Errorf("need Fields to sum")
+		}
+	default:
+		return fmt.Errorf("has unknown check %q", rule.Check)
+	}
+	return nil
+}
+
+// sumRuleFields add values of fields nearest to node, false if any value is not a number.
+func sumRuleFields(node *xmlNode, fields []string) (float64, bool) {
+	total := 0.0
+	for _, field := range fields {
+		for _, other := range node.Relative(field) {
+			x, err := strconv.ParseFloat(other.Value(), 64)
+			if err != nil {
+				return 0, false
+			}
+			total += x
+		}
+	}
+	return total, true
+}
+
+// validThaiTaxID check 13 digits tax id with mod 11 check digit.
+func validThaiTaxID(id string) bool {
+	if len(id) != 13 {
+		return false
+	}
+	sum := 0
+	for i := 0; i < 13; i++ {
+		if id[i] < '0' || id[i] > '9' {
+			return false
+		}
+		if i < 12 {
+			sum += int(id[i]-'0') * (13 - i)
+		}
+	}
+	return (11-sum%11)%10 == int(id[12]-'0')
+}
+
+func compareRuleValue(a, b string, operator string) bool {
+	result := strings.Compare(a, b)
+	if x, err := strconv.ParseFloat(a, 64); err == nil {
+		if y, err := strconv.ParseFloat(b, 64); err == nil {
+			switch {
+			case x < y:
+				result = -1
+			case x > y:
+				result = 1
+			default:
+				result = 0
+			}
+		}
+	}
+	switch operator {
+	case "==":
+		return result == 0
+	case "!=":
+		return result != 0
+	case "<":
+		return result < 0
+	case "<=":
+		return result <= 0
+	case ">":
+		return result > 0
+	case ">=":
+		return result >= 0
+	}
+	return false
+}
+
+func (v *validator) ruleViolation(ruleID string, rule *RuleCheck, field *Field, node *xmlNode, format string, a ...interface{}) {
+	d := v.errorf("validate", field, node.Line, node.Location, format, a...)
+	d.Rule = ruleID
+	if rule.Description != "" {
+		d.Message += " (" + rule.Description + ")"
+	}
+}
+
+// applyRuleCheck check every element of field against rule.
+func (v *validator) applyRuleCheck(ruleID string, rule *RuleCheck, field *Field, root *xmlNode, noCodeList map[string]bool) {
+	if rule.Check == "requiredIf" {
+		parentKey := ""
+		if dot := strings.LastIndex(field.FromKey, "."); dot >= 0 {
+			parentKey = field.FromKey[:dot]
+		}
+		parents := []*xmlNode{root}
+		if parentKey != "" {
+			parents = root.Find(parentKey)
+		}
+		for _, parent := range parents {
+			conditionMet := false
+			for _, condition := range parent.Relative(rule.Field) {
+				if len(rule.Values) == 0 || stringInSlice(condition.Value(), rule.Values) {
+					conditionMet = true
+				}
+			}
+			if conditionMet && len(parent.Find(field.FromKey)) == 0 {
+				condition := "present"
+				if len(rule.Values) > 0 {
+					condition = strings.Join(rule.Values, " or ")
+				}
+				v.ruleViolation(ruleID, rule, field, parent, "%s is required when %s is %s", field.FromKey, rule.Field, condition)
+			}
+		}
+		return
+	}
+	for _, node := range root.Find(field.FromKey) {
+		value := node.Value()
+		switch rule.Check {
+		case "regex":
+			if !rule.pattern.MatchString(value) {
+				v.ruleViolation(ruleID, rule, field, node, "value %q does not match %s", value, rule.Pattern)
+			}
+		case "checksum":
+			if !validThaiTaxID(value) {
+				v.ruleViolation(ruleID, rule, field, node, "value %q is not valid tax id", value)
+			}
+		case "enum":
+			values := rule.Values
+			if len(values) == 0 {
+				values = field.Values
+			}
+			if len(values) == 0 {
+				if !noCodeList[field.FromKey] {
+					noCodeList[field.FromKey] = true
+					v.diagnostics.add(SeverityWarning, "validate", v.file, nil, "rule %s has no Values and %s has no code list", ruleID, field.FromKey)
+				}
+			} else if !stringInSlice(value, values) {
+				v.ruleViolation(ruleID, rule, field, node, "value %q is not one of %s", value, strings.Join(values, ", "))
+			}
+		case "compare":
+			for _, other := range node.Relative(rule.Field) {
+				if !compareRuleValue(value, other.Value(), rule.Operator) {
+					v.ruleViolation(ruleID, rule, field, node, "value %q must be %s %s (%q)", value, rule.Operator, rule.Field, other.Value())
+				}
+			}
+		case "sum":
+			if total, ok := sumRuleFields(node, rule.Fields); !ok {
+				v.ruleViolation(ruleID, rule, field, node, "value of %s is not a number", strings.Join(rule.Fields, ", "))
+			} else if x, err := strconv.ParseFloat(value, 64); err != nil || math.Abs(x-total) >= 0.005 {
+				v.ruleViolation(ruleID, rule, field, node, "value %q must be sum of %s (%s)", value, strings.Join(rule.Fields, ", "),
+					strconv.FormatFloat(total, 'f', -1, 64))
+			}
+		}
+	}
+}
+
+// validateRuleGuideline apply rule catalogue to every element of xml referred by spec field with rule guideline.
+// Diagnostics follow order of fields in spec.
+func (v *validator) validateRuleGuideline(jsonInput []Field, root *xmlNode, catalogue RuleCatalogue) {
+	type fieldCheck struct {
+		index  int
+		ruleID string
+		rule   *RuleCheck
+	}
+	indexes := map[string]int{}
+	referring := map[string][]int{}
+	var ruleIDs []string
+	for i := range jsonInput {
+		indexes[jsonInput[i].FromKey] = i
+		for _, ruleID := range jsonInput[i].Rules {
+			if _, ok := referring[ruleID]; !ok {
+				ruleIDs = append(ruleIDs, ruleID)
+			}
+			referring[ruleID] = append(referring[ruleID], i)
+		}
+	}
+	var checks []fieldCheck
+	var undefinedRules []string
+	for _, ruleID := range ruleIDs {
+		rules, ok := catalogue[ruleID]
+		if !ok {
+			undefinedRules = append(undefinedRules, ruleID)
+			continue
+		}
+		for _, rule := range rules {
+			if len(rule.FromKeys) == 0 {
+				for _, i := range referring[ruleID] {
+					checks = append(checks, fieldCheck{i, ruleID, rule})
+				}
+				continue
+			}
+			for _, fromKey := range rule.FromKeys {
+				if i, ok := indexes[fromKey]; ok {
+					checks = append(checks, fieldCheck{i, ruleID, rule})
+				} else {
+					v.diagnostics.add(SeverityWarning, "validate", v.file, nil, "rule %s check %s which is not in spec", ruleID, fromKey)
+				}
+			}
+		}
+	}
+	sort.SliceStable(checks, func(i, j int) bool { return checks[i].index < checks[j].index })
+	noCodeList := map[string]bool{}
+	for _, check := range checks {
+		v.applyRuleCheck(check.ruleID, check.rule, &jsonInput[check.index], root, noCodeList)
+	}
+	if len(undefinedRules) > 0 {
+		sort.Strings(undefinedRules)
+		v.diagnostics.add(SeverityWarning, "validate", v.file, nil, "%d rule(s) referenced by spec are not in catalogue: %s",
+			len(undefinedRules), strings.Join(undefinedRules, ", "))
+	}
+}
@@ -0,0 +1,205 @@
+package rdefiling
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+// ruleMessages validate document against fields and catalogue, return rule and message of rule guideline diagnostics.
+func ruleMessages(t *testing.T, fields []Field, catalogue RuleCatalogue, document string) []string {
+	t.Helper()
+	spec := &Spec{File: "spec.csv", Fields: fields}
+	diagnostics, err := spec.Validate(strings.NewReader(document), "test.xml", catalogue, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var messages []string
+	for _, d := range diagnostics {
+		if d.Rule != "" || d.Severity == SeverityWarning {
+			messages = append(messages, d.Rule+": "+d.Message)
+		}
+	}
+	return messages
+}
+
+func TestEnumRuleFromCodeList(t *testing.T) {
+	fields := []Field{
+		{FromKey: "TaxForm", Type: "Object"},
+		{FromKey: "TaxForm.FilingType", Type: "String", Rules: []string{"R1"}},
+		{FromKey: "TaxForm.FormType", Type: "Number", Rules: []string{"R2"}},
+	}
+	spec := &Spec{File: "spec.csv", Fields: fields}
+	spec.ApplyCodeList(&CodeList{Enumerations: map[string][]string{"TaxForm.FilingType": {"0", "1"}}})
+	catalogue := RuleCatalogue{"R1": {{Check: "enum"}}, "R2": {{Check: "enum"}}}
+	document := `<rd:RdForm xmlns:rd="urn:schemas-rd-go-th:xml-services:common"><rd:TaxForm>` +
+		`<rd:FilingType>2</rd:FilingType><rd:FormType>9</rd:FormType></rd:TaxForm></rd:RdForm>`
+	messages := ruleMessages(t, spec.Fields, catalogue, document)
+	want := []string{
+		`R1: value "2" is not one of 0, 1`,
+		`: rule R2 has no Values and TaxForm.FormType has no code list`,
+	}
+	if strings.Join(messages, "\n") != strings.Join(want, "\n") {
+		t.Errorf("diagnostics\n%s\nwant\n%s", strings.Join(messages, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+// TestFormEnumRulesHaveCodeList keep catalogue of form consistent with its code list, enum rules take their values
+// from code list so every field referring to them must have one.
+func TestFormEnumRulesHaveCodeList(t *testing.T) {
+	form, err := ReadForm(xsdGoldenForm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spec, err := form.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	file, err := os.Open(form.Path(form.RuleCatalogue))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	catalogue, err := ReadRuleCatalogue(file, form.RuleCatalogue)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, field := range spec.Fields {
+		for _, id := range field.Rules {
+			for _, rule := range catalogue[id] {
+				if rule.Check != "enum" || len(rule.FromKeys) > 0 {
+					continue
+				}
+				if len(rule.Values) > 0 {
+					t.Errorf("rule %s repeat values of code list", id)
+				} else if len(field.Values) == 0 {
+					t.Errorf("%s has enum rule %s but no code list", field.FromKey, id)
+				}
+			}
+		}
+	}
+}
+
+func TestReadRuleCatalogue(t *testing.T) {
+	catalogue, err := ReadRuleCatalogue(strings.NewReader(`{
+	"R1": {"Check": "regex", "Pattern": "[0-9]{4}"},
+	"R2": [
+		{"Check": "regex", "FromKeys": ["A.B"], "Pattern": "[0-9]"},
+		{"Check": "requiredIf", "FromKeys": ["A.C"], "Field": "A.B"}
+	]
+}`), "catalogue.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(catalogue["R1"]) != 1 || len(catalogue["R2"]) != 2 || len(catalogue["Common_R23"]) != 1 {
+		t.Errorf("catalogue %v, want R1 with 1 check, R2 with 2 checks and built-in Common_R23", catalogue)
+	}
+
+	for _, test := range []struct{ catalogue, want string }{
+		{`{"R1": null}`, "rule R1 is null"},
+		{`{"R1": [null]}`, "rule R1 is null"},
+		{`{"R1": []}`, "rule R1 has no check"},
+		{`{"R1": {"Check": "manual"}}`, `rule R1 has unknown check "manual"`},
+		{`{"R1": [{"Check": "compare", "Operator": ">"}]}`, "rule R1 need Field to compare with"},
+	} {
+		if _, err := ReadRuleCatalogue(strings.NewReader(test.catalogue), "catalogue.json"); err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("%s: error %v, want %q", test.catalogue, err, test.want)
+		}
+	}
+}
+
+func TestRuleCheckFromKeys(t *testing.T) {
+	fields := []Field{
+		{FromKey: "Period", Type: "Object", Rules: []string{"R8"}},
+		{FromKey: "Period.Month", Type: "Number", Rules: []string{"R8"}},
+		{FromKey: "Period.StartDate", Type: "Date", Rules: []string{"R8"}},
+		{FromKey: "Period.EndDate", Type: "Date", Rules: []string{"R8"}},
+	}
+	catalogue := RuleCatalogue{"R8": {
+		{Check: "compare", FromKeys: []string{"Period.EndDate"}, Operator: ">", Field: "Period.StartDate"},
+		{Check: "regex", FromKeys: []string{"Period.Month", "Period.Year"}, Pattern: "[1-9]|1[0-2]"},
+		{Check: "requiredIf", FromKeys: []string{"Period.StartDate"}, Field: "Period.EndDate"},
+	}}
+	for _, rules := range catalogue {
+		for _, rule := range rules {
+			if err := rule.compile(); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	document := `<rd:RdForm xmlns:rd="urn:schemas-rd-go-th:xml-services:common"><rd:Period>` +
+		`<rd:Month>13</rd:Month><rd:EndDate>2020-01-01</rd:EndDate></rd:Period>` +
+		`<rd:Period><rd:Month>12</rd:Month><rd:StartDate>2020-01-01</rd:StartDate><rd:EndDate>2020-12-31</rd:EndDate></rd:Period></rd:RdForm>`
+	messages := ruleMessages(t, fields, catalogue, document)
+	want := []string{
+		": rule R8 check Period.Year which is not in spec",
+		`R8: value "13" does not match [1-9]|1[0-2]`,
+		"R8: Period.StartDate is required when Period.EndDate is present",
+	}
+	if strings.Join(messages, "\n") != strings.Join(want, "\n") {
+		t.Errorf("diagnostics\n%s\nwant\n%s", strings.Join(messages, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+// TestFormCommonRules check that common rules of pnd50 catalogue catch wrong values and accept right ones.
+func TestFormCommonRules(t *testing.T) {
+	form, err := ReadForm(xsdGoldenForm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spec, err := form.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	file, err := os.Open(form.Path(form.RuleCatalogue))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	catalogue, err := ReadRuleCatalogue(file, form.RuleCatalogue)
+	if err != nil {
+		t.Fatal(err)
+	}
+	commonMessages := func(document string) []string {
+		var messages []string
+		for _, message := range ruleMessages(t, spec.Fields, catalogue, `<rd:RdForm xmlns:rd="urn:schemas-rd-go-th:xml-services:common">`+document+`</rd:RdForm>`) {
+			if strings.HasPrefix(message, "Common_") {
+				messages = append(messages, message[:strings.LastIndex(message, " (")])
+			}
+		}
+		return messages
+	}
+
+	valid := `<rd:ExchangeDocument><rd:FormType>PND50</rd:FormType><rd:Version>3.0.0</rd:Version></rd:ExchangeDocument>` +
+		`<rd:Sender><rd:Id>0105527003992</rd:Id><rd:BranchNo>0</rd:BranchNo><rd:BranchType>H</rd:BranchType><rd:Role>1</rd:Role></rd:Sender>` +
+		`<rd:TaxPayer><rd:BirthDate><rd:Year>1990</rd:Year><rd:Month>2</rd:Month><rd:Day>28</rd:Day></rd:BirthDate>` +
+		`<rd:Address><rd:CityName>Bang Rak</rd:CityName><rd:PostCode>10500</rd:PostCode></rd:Address></rd:TaxPayer>` +
+		`<rd:TaxForm><rd:TaxPeriod><rd:StartDate>2020-01-01</rd:StartDate><rd:EndDate>2020-12-31</rd:EndDate></rd:TaxPeriod>` +
+		`<rd:Filing><rd:FilingType>1</rd:FilingType><rd:FilingNo>1</rd:FilingNo></rd:Filing></rd:TaxForm>`
+	if messages := commonMessages(valid); len(messages) != 0 {
+		t.Errorf("valid document has diagnostics %q", messages)
+	}
+
+	invalid := `<rd:ExchangeDocument><rd:FormType>PND51</rd:FormType><rd:Version>3</rd:Version></rd:ExchangeDocument>` +
+		`<rd:Sender><rd:Id>0105527003992</rd:Id><rd:BranchType>X</rd:BranchType></rd:Sender>` +
+		`<rd:TaxPayer><rd:BirthDate><rd:Year>90</rd:Year><rd:Day>28</rd:Day></rd:BirthDate>` +
+		`<rd:Address><rd:CityName> </rd:CityName><rd:PostCode>1050</rd:PostCode></rd:Address></rd:TaxPayer>` +
+		`<rd:TaxForm><rd:TaxPeriod><rd:StartDate>2020-12-31</rd:StartDate><rd:EndDate>2020-01-01</rd:EndDate></rd:TaxPeriod>` +
+		`<rd:Filing><rd:FilingType>1</rd:FilingType><rd:FilingNo>0</rd:FilingNo></rd:Filing></rd:TaxForm>`
+	want := []string{
+		`Common_R2: value "PND51" is not one of PND50`,
+		`Common_R3: value "3" does not match [0-9]+\.[0-9]+\.[0-9]+`,
+		"Common_R11: Sender.BranchNo is required when Sender is present",
+		`Common_R7: value "X" is not one of H, B`,
+		"Common_R11: Sender.Role is required when Sender is present",
+		`Common_R15: value "90" does not match [0-9]{4}`,
+		"Common_R15: TaxPayer.BirthDate.Month is required when TaxPayer.BirthDate.Day is present",
+		`Common_R16: value "" does not match \S(.*\S)?`,
+		`Common_R16: value "1050" does not match [1-9][0-9]{4}`,
+		`Common_R8: value "2020-01-01" must be > TaxForm.TaxPeriod.StartDate ("2020-12-31")`,
+		`Common_R9: value "0" must be >= TaxForm.Filing.FilingType ("1")`,
+	}
+	if messages := commonMessages(invalid); strings.Join(messages, "\n") != strings.Join(want, "\n") {
+		t.Errorf("diagnostics\n%s\nwant\n%s", strings.Join(messages, "\n"), strings.Join(want, "\n"))
+	}
+}
@@ -0,0 +1,275 @@
+package rdefiling
+
+import (
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// schemaElement is element declaration in the same structure createXsd generates from spec.
+type schemaElement struct {
+	Field     *Field // nil for parent which is not in spec
+	Key       string // FromKey, "" is RdForm
+	Name      string
+	MinOccurs int
+	MaxOccurs int // -1 is unbounded
+	Simple    bool
+	Children  []*schemaElement
+}
+
+func buildSchemaTree(jsonInput []Field) *schemaElement {
+	root := &schemaElement{Name: "RdForm", MinOccurs: 1, MaxOccurs: 1}
+	elements := map[string]*schemaElement{"": root}
+	for i := range jsonInput {
+		field := &jsonInput[i]
+		tokens := strings.Split(field.FromKey, ".")
+		for i := range tokens {
+			key := strings.Join(tokens[:i+1], ".")
+			if _, ok := elements[key]; ok {
+				continue
+			}
+			element := &schemaElement{Key: key, Name: tokens[i], MinOccurs: 1, MaxOccurs: 1}
+			parent := elements[strings.Join(tokens[:i], ".")]
+			parent.Children = append(parent.Children, element)
+			elements[key] = element
+		}
+		element := elements[field.FromKey]
+		element.Field = field
+		if min, max, ok := parseMultiple(field.Multiple); ok {
+			element.MinOccurs, element.MaxOccurs = min, max
+		}
+		switch field.Type {
+		case "Object", "Array":
+		default:
+			element.Simple = true
+		}
+	}
+	return root
+}
+
+var (
+	integerPattern = regexp.MustCompile(`^[+-]?[0-9]+$`)
+	decimalPattern = regexp.MustCompile(`^[+-]?([0-9]+(\.[0-9]*)?|\.[0-9]+)$`)
+	datePattern    = regexp.MustCompile(`^[0-9]{4}-[0-9]{2}-[0-9]{2}(Z|[+-][0-9]{2}:[0-9]{2})?$`)
+)
+
+// countDigits return total and fraction digits of decimal lexical value as defined by xsd facets.
+func countDigits(value string) (total int, fraction int) {
+	value = strings.TrimLeft(value, "+-")
+	integer := value
+	if dot := strings.Index(value, "."); dot >= 0 {
+		integer = value[:dot]
+		fraction = len(strings.TrimRight(value[dot+1:], "0"))
+	}
+	integer = strings.TrimLeft(integer, "0")
+	total = len(integer) + fraction
+	if total == 0 {
+		total = 1
+	}
+	return total, fraction
+}
+
+// checkSimpleValue check lexical form and facets of value of spec field, return reason when value is invalid.
+func checkSimpleValue(field *Field, value string) string {
+	if field.Type != "String" {
+		value = strings.TrimSpace(value) // whitespace collapse for every type except string
+	}
+	switch {
+	case field.Type == "String":
+		if max, err := strconv.Atoi(field.MaxLength); err == nil && utf8.RuneCountInString(value) > max {
+			return "length " + strconv.Itoa(utf8.RuneCountInString(value)) + " exceeds maxLength " + field.MaxLength
+		}
+	case field.Type == "Number":
+		if !integerPattern.MatchString(value) {
+			return "value " + strconv.Quote(value) + " is not xs:integer"
+		}
+		if max, err := strconv.Atoi(field.MaxLength); err == nil {
+			if total, _ := countDigits(value); total > max {
+				return "value " + value + " exceeds totalDigits " + field.MaxLength
+			}
+		}
+	case strings.HasPrefix(field.Type, "Decimal"):
+		if !decimalPattern.MatchString(value) {
+			return "value " + strconv.Quote(value) + " is not xs:decimal"
+		}
+		if precision, scale, ok := parseDecimalType(field.Type); ok {
+			total, fraction := countDigits(value)
+			if total > precision {
+				return "value " + value + " exceeds totalDigits " + strconv.Itoa(precision)
+			}
+			if fraction > scale {
+				return "value " + value + " exceeds fractionDigits " + strconv.Itoa(scale)
+			}
+		}
+	case field.Type == "Date":
+		if !datePattern.MatchString(value) {
+			return "value " + strconv.Quote(value) + " is not xs:date, expect YYYY-MM-DD"
+		}
+		if _, err := time.Parse("2006-01-02", value[:10]); err != nil {
+			return "value " + strconv.Quote(value) + " is not valid date"
+		}
+	case field.Type == "DateTime":
+		if !dateTimePattern.MatchString(value) {
+			return "value " + strconv.Quote(value) + " is not xs:dateTime, expect YYYY-MM-DDThh:mm:ss+07:00"
+		}
+		if _, err := time.Parse("2006-01-02T15:04:05", value[:19]); err != nil {
+			return "value " + strconv.Quote(value) + " is not valid date time"
+		}
+	case field.Type == "Time":
+		if !timePattern.MatchString(value) {
+			return "value " + strconv.Quote(value) + " is not xs:time, expect hh:mm:ss"
+		}
+		if _, err := time.Parse("15:04:05", value[:8]); err != nil {
+			return "value " + strconv.Quote(value) + " is not valid time"
+		}
+	case field.Type == "Year":
+		if !yearPattern.MatchString(value) {
+			return "value " + strconv.Quote(value) + " is not xs:gYear, expect YYYY"
+		}
+	case field.Type == "Boolean":
+		switch value {
+		case "true", "false", "1", "0":
+		default:
+			return "value " + strconv.Quote(value) + " is not xs:boolean, expect true, false, 1 or 0"
+		}
+	}
+	if len(field.Values) > 0 && !stringInSlice(value, field.Values) {
+		return "value " + strconv.Quote(value) + " is not one of " + strings.Join(field.Values, ", ")
+	}
+	if pattern := field.patternRegexp(); pattern != nil && !pattern.MatchString(value) {
+		return "value " + strconv.Quote(value) + " does not match pattern " + field.Pattern
+	}
+	return ""
+}
+
+// validator collect problems of one validated xml document.
+type validator struct {
+	file        string
+	diagnostics Diagnostics
+}
+
+func (v *validator) errorf(stage string, field *Field, line int, path string, format string, a ...interface{}) *Diagnostic {
+	d := v.diagnostics.add(SeverityError, stage, v.file, field, format, a...)
+	d.Line = line
+	d.Path = path
+	return d
+}
+
+func (v *validator) schemaViolation(field *Field, line int, path string, format string, a ...interface{}) {
+	v.errorf("schema", field, line, path, format, a...)
+}
+
+// validateSchema check document against schema built from spec, element order, occurrence and simple type facets.
+// ds:Signature is allowed only as last child of signatureElements, same as XSDOptions.SignatureElements.
+func (v *validator) validateSchema(jsonInput []Field, root *xmlNode, signatureElements []string) {
+	schema := buildSchemaTree(jsonInput)
+	signed := map[string]bool{"": signatureElements == nil}
+	for _, key := range signatureElements {
+		if key == "RdForm" {
+			key = ""
+		}
+		signed[key] = true
+	}
+	if root.Name != schema.Name || root.Space != XMLNameSpace {
+		v.schemaViolation(nil, root.Line, root.Location, "root element must be {%s}%s but got {%s}%s", XMLNameSpace, schema.Name, root.Space, root.Name)
+		return
+	}
+	var validate func(*schemaElement, *xmlNode)
+	validate = func(element *schemaElement, node *xmlNode) {
+		if element.Simple {
+			if len(node.Children) > 0 {
+				v.schemaViolation(element.Field, node.Line, node.Location, "element of simple type must not have child element")
+				return
+			}
+			if reason := checkSimpleValue(element.Field, node.Data); reason != "" {
+				v.schemaViolation(element.Field, node.Line, node.Location, "%s", reason)
+			}
+			return
+		}
+		if strings.TrimSpace(node.Data) != "" {
+			v.schemaViolation(element.Field, node.Line, node.Location, "element of complex type must not have text content")
+		}
+		children := node.Children
+		if signed[element.Key] && len(children) > 0 {
+			if last := children[len(children)-1]; last.Name == "Signature" && last.Space == XMLDSigNameSpace {
+				children = children[:len(children)-1]
+			}
+		}
+		missing := func(child *schemaElement, count int) {
+			if count < child.MinOccurs {
+				v.schemaViolation(child.Field, node.Line, node.Location+"/"+child.Name, "element %s occurs %d time(s), expect at least %d", child.Name, count, child.MinOccurs)
+			}
+		}
+		indexOf := func(name string, from int) int {
+			for i := from; i < len(element.Children); i++ {
+				if element.Children[i].Name == name {
+					return i
+				}
+			}
+			return -1
+		}
+		current, count := 0, 0
+		for _, child := range children {
+			if child.Name == "Signature" && child.Space == XMLDSigNameSpace {
+				if signed[element.Key] {
+					v.schemaViolation(nil, child.Line, child.Location, "ds:Signature must be last child of %s", node.Name)
+				} else {
+					v.schemaViolation(nil, child.Line, child.Location, "ds:Signature is not allowed in %s, only in signature elements of form", node.Name)
+				}
+				continue
+			}
+			if child.Space != XMLNameSpace {
+				v.schemaViolation(nil, child.Line, child.Location, "element %s must be in namespace %s", child.Name, XMLNameSpace)
+				continue
+			}
+			if current < len(element.Children) && element.Children[current].Name == child.Name {
+				count++
+			} else if next := indexOf(child.Name, current+1); next >= 0 {
+				if current < len(element.Children) {
+					missing(element.Children[current], count)
+				}
+				for _, skipped := range element.Children[current+1 : next] {
+					missing(skipped, 0)
+				}
+				current, count = next, 1
+			} else if indexOf(child.Name, 0) >= 0 {
+				v.schemaViolation(nil, child.Line, child.Location, "element %s is out of order", child.Name)
+				continue
+			} else {
+				v.schemaViolation(nil, child.Line, child.Location, "unexpected element %s", child.Name)
+				continue
+			}
+			childElement := element.Children[current]
+			if childElement.MaxOccurs >= 0 && count > childElement.MaxOccurs {
+				v.schemaViolation(childElement.Field, child.Line, child.Location, "element %s occurs more than %d time(s)", child.Name, childElement.MaxOccurs)
+			}
+			validate(childElement, child)
+		}
+		if current < len(element.Children) {
+			missing(element.Children[current], count)
+			for _, rest := range element.Children[current+1:] {
+				missing(rest, 0)
+			}
+		}
+	}
+	validate(schema, root)
+}
+
+// Validate check RdForm xml against schema of spec then rule guideline of catalogue, name is used in diagnostics.
+// Catalogue can be nil to skip rule guideline validation. SignatureElements are FromKey of objects which may carry
+// ds:Signature as their last child, nil means RdForm only.
+func (s *Spec) Validate(reader io.Reader, name string, catalogue RuleCatalogue, signatureElements []string) (Diagnostics, error) {
+	root, err := parseXMLTree(reader)
+	if err != nil {
+		return nil, fatalError("validate", name, 0, "can't parse xml: %v", err)
+	}
+	v := &validator{file: name}
+	v.validateSchema(s.Fields, root, signatureElements)
+	if catalogue != nil {
+		v.validateRuleGuideline(s.Fields, root, catalogue)
+	}
+	return v.diagnostics, nil
+}
@@ -0,0 +1,100 @@
+package rdefiling
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheckSimpleValue(t *testing.T) {
+	tests := []struct {
+		field Field
+		value string
+		want  string // "" is valid
+	}{
+		{Field{Type: "Number", MaxLength: "3"}, "007", ""},
+		{Field{Type: "Number", MaxLength: "3"}, "+000123", ""},
+		{Field{Type: "Number", MaxLength: "3"}, "0001234", "value 0001234 exceeds totalDigits 3"},
+		{Field{Type: "Number", MaxLength: "3"}, "000", ""},
+		{Field{Type: "Number"}, "1.0", `value "1.0" is not xs:integer`},
+		{Field{Type: "Decimal(5,2)"}, "000123.45", ""},
+		{Field{Type: "Decimal(5,2)"}, "-0012.50", ""},
+		{Field{Type: "Decimal(5,2)"}, "1.2300", ""},
+		{Field{Type: "Decimal(5,2)"}, "123.4500000", ""},
+		{Field{Type: "Decimal(5,2)"}, "1.235", "value 1.235 exceeds fractionDigits 2"},
+		{Field{Type: "Decimal(5,2)"}, "12345.6", "value 12345.6 exceeds totalDigits 5"},
+		{Field{Type: "Decimal(5,2)"}, "12345", ""},
+		{Field{Type: "Decimal(5,2)"}, "0.00", ""},
+		{Field{Type: "Decimal(5,2)"}, "1e3", `value "1e3" is not xs:decimal`},
+		{Field{Type: "Date"}, "2021-02-28", ""},
+		{Field{Type: "Date"}, " 2020-02-29+07:00 ", ""},
+		{Field{Type: "Date"}, "2021-02-30", `value "2021-02-30" is not valid date`},
+		{Field{Type: "Date"}, "2021-13-01", `value "2021-13-01" is not valid date`},
+		{Field{Type: "Date"}, "2021-2-3", `value "2021-2-3" is not xs:date, expect YYYY-MM-DD`},
+		{Field{Type: "DateTime"}, "2021-02-28T24:00:00+07:00", `value "2021-02-28T24:00:00+07:00" is not valid date time`},
+		{Field{Type: "Time"}, "23:59:59", ""},
+		{Field{Type: "Year"}, "21", `value "21" is not xs:gYear, expect YYYY`},
+		{Field{Type: "Boolean"}, "yes", `value "yes" is not xs:boolean, expect true, false, 1 or 0`},
+		{Field{Type: "String", MaxLength: "4"}, "ภาษี", ""},
+		{Field{Type: "String", MaxLength: "3"}, "ภาษี", "length 4 exceeds maxLength 3"},
+		{Field{Type: "String", Values: []string{"H", "B"}}, "X", `value "X" is not one of H, B`},
+	}
+	for _, test := range tests {
+		if got := checkSimpleValue(&test.field, test.value); got != test.want {
+			t.Errorf("%s %q: got %q, want %q", test.field.Type, test.value, got, test.want)
+		}
+	}
+}
+
+var validateSchemaTestFields = []Field{
+	{FromKey: "TaxPayer", Type: "Object"},
+	{FromKey: "TaxPayer.Id", Type: "String"},
+	{FromKey: "TaxPayer.Name", Type: "String"},
+	{FromKey: "TaxPayer.BirthDate", Type: "Date", Multiple: "[0…1]"},
+	{FromKey: "TaxPayer.Address", Type: "String"},
+}
+
+func TestValidateSchema(t *testing.T) {
+	tests := []struct {
+		name     string
+		taxPayer string
+		want     []string // path and message of diagnostics
+	}{
+		{"valid", `<rd:Id>1</rd:Id><rd:Name>A</rd:Name><rd:BirthDate>2000-01-31</rd:BirthDate><rd:Address>B</rd:Address>`, nil},
+		{"optional element left out", `<rd:Id>1</rd:Id><rd:Name>A</rd:Name><rd:Address>B</rd:Address>`, nil},
+		{"missing element", `<rd:Id>1</rd:Id><rd:BirthDate>2000-01-31</rd:BirthDate><rd:Address>B</rd:Address>`, []string{
+			"/RdForm/TaxPayer[1]/Name: element Name occurs 0 time(s), expect at least 1",
+		}},
+		{"missing last element", `<rd:Id>1</rd:Id><rd:Name>A</rd:Name>`, []string{
+			"/RdForm/TaxPayer[1]/Address: element Address occurs 0 time(s), expect at least 1",
+		}},
+		{"out of order", `<rd:Id>1</rd:Id><rd:Address>B</rd:Address><rd:Name>A</rd:Name>`, []string{
+			"/RdForm/TaxPayer[1]/Name: element Name occurs 0 time(s), expect at least 1",
+			"/RdForm/TaxPayer[1]/Name[1]: element Name is out of order",
+		}},
+		{"occurs too often", `<rd:Id>1</rd:Id><rd:Name>A</rd:Name><rd:BirthDate>2000-01-31</rd:BirthDate>` +
+			`<rd:BirthDate>2000-02-30</rd:BirthDate><rd:Address>B</rd:Address>`, []string{
+			"/RdForm/TaxPayer[1]/BirthDate[2]: element BirthDate occurs more than 1 time(s)",
+			`/RdForm/TaxPayer[1]/BirthDate[2]: value "2000-02-30" is not valid date`,
+		}},
+		{"unexpected element", `<rd:Id>1</rd:Id><rd:Name>A</rd:Name><rd:Nickname>C</rd:Nickname><rd:Address>B</rd:Address>`, []string{
+			"/RdForm/TaxPayer[1]/Nickname[1]: unexpected element Nickname",
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			spec := &Spec{File: "spec.csv", Fields: validateSchemaTestFields}
+			document := `<rd:RdForm xmlns:rd="urn:schemas-rd-go-th:xml-services:common"><rd:TaxPayer>` + test.taxPayer + `</rd:TaxPayer></rd:RdForm>`
+			diagnostics, err := spec.Validate(strings.NewReader(document), "test.xml", nil, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, d := range diagnostics {
+				got = append(got, d.Path+": "+d.Message)
+			}
+			if strings.Join(got, "\n") != strings.Join(test.want, "\n") {
+				t.Errorf("diagnostics\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(test.want, "\n"))
+			}
+		})
+	}
+}
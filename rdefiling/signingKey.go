@@ -0,0 +1,138 @@
+package rdefiling
+
+import (
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+)
+
+// SigningKey is RSA private key with its certificate, following certificates are chain put into KeyInfo too.
+type SigningKey struct {
+	PrivateKey   *rsa.PrivateKey
+	Certificates []*x509.Certificate
+}
+
+// ReadSigningKey read private key and certificate from PKCS#12 file (.p12 or .pfx) or from PEM files.
+// PEM key may be PKCS#1, PKCS#8 or encrypted PKCS#8, certFile may be empty when keyFile contains certificate too.
+func ReadSigningKey(keyFile, certFile, password string) (*SigningKey, error) {
+	data, err := ioutil.ReadFile(keyFile)
+	if err != nil {
+		return nil, fatalError("sign", keyFile, 0, "%v", err)
+	}
+	var key interface{}
+	var certificates []*x509.Certificate
+	if ext := strings.ToLower(filepath.Ext(keyFile)); ext == ".p12" || ext == ".pfx" {
+		if key, certificates, err = parsePKCS12(data, password); err != nil {
+			return nil, fatalError("sign", keyFile, 0, "%v", err)
+		}
+	} else {
+		if key, certificates, err = parsePEMKey(data, password); err != nil {
+			return nil, fatalError("sign", keyFile, 0, "%v", err)
+		}
+	}
+	if certFile != "" {
+		more, err := ReadCertificates(certFile)
+		if err != nil {
+			return nil, err
+		}
+		certificates = append(certificates, more...)
+	}
+	privateKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fatalError("sign", keyFile, 0, "need RSA private key for RSA-SHA256 signature")
+	}
+	// certificate of key go first, the rest is kept in given order
+	for i, certificate := range certificates {
+		if publicKey, ok := certificate.PublicKey.(*rsa.PublicKey); ok && publicKey.N.Cmp(privateKey.N) == 0 && publicKey.E == privateKey.E {
+			certificates = append(append([]*x509.Certificate{certificate}, certificates[:i]...), certificates[i+1:]...)
+			return &SigningKey{PrivateKey: privateKey, Certificates: certificates}, nil
+		}
+	}
+	name := certFile
+	if name == "" {
+		name = keyFile
+	}
+	return nil, fatalError("sign", name, 0, "no certificate of private key")
+}
+
+// parsePEMKey read every private key and certificate block of PEM data, exactly one private key is expected.
+func parsePEMKey(data []byte, password string) (interface{}, []*x509.Certificate, error) {
+	var key interface{}
+	var certificates []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		der := block.Bytes
+		switch block.Type {
+		case "CERTIFICATE":
+			certificate, err := x509.ParseCertificate(der)
+			if err != nil {
+				return nil, nil, err
+			}
+			certificates = append(certificates, certificate)
+			continue
+		case "ENCRYPTED PRIVATE KEY":
+			var err error
+			if der, err = decryptPKCS8(der, password); err != nil {
+				return nil, nil, err
+			}
+		case "RSA PRIVATE KEY", "PRIVATE KEY":
+			if block.Headers["Proc-Type"] != "" {
+				return nil, nil, errors.New("legacy encrypted PEM key is not supported, convert it to PKCS#8 with openssl pkcs8 -topk8")
+			}
+		default:
+			continue
+		}
+		if key != nil {
+			return nil, nil, errors.New("more than one private key")
+		}
+		var err error
+		if block.Type == "RSA PRIVATE KEY" {
+			key, err = x509.ParsePKCS1PrivateKey(der)
+		} else {
+			key, err = x509.ParsePKCS8PrivateKey(der)
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	if key == nil {
+		return nil, nil, errors.New("no private key in PEM file")
+	}
+	return key, certificates, nil
+}
+
+// ReadCertificates read every certificate of PEM file.
+func ReadCertificates(path string) ([]*x509.Certificate, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fatalError("sign", path, 0, "%v", err)
+	}
+	var certificates []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		certificate, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fatalError("sign", path, 0, "%v", err)
+		}
+		certificates = append(certificates, certificate)
+	}
+	if len(certificates) == 0 {
+		return nil, fatalError("sign", path, 0, "no certificate in PEM file")
+	}
+	return certificates, nil
+}
@@ -0,0 +1,160 @@
+package rdefiling
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Fixtures in testdata/signingKey are made by openssl 3 from key.pem and cert.pem:
+//
+//	openssl req -x509 -newkey rsa:2048 -nodes -keyout key.pem -out cert.pem -days 36500 -subj "/CN=test signer"
+//	openssl rsa -in key.pem -traditional -out key-pkcs1.pem
+//	openssl pkcs8 -topk8 -in key.pem -v2 aes-256-cbc -passout pass:secret -out key-encrypted.pem
+//	openssl rsa -in key.pem -traditional -aes256 -passout pass:secret -out key-legacy-encrypted.pem
+//	openssl pkcs12 -export -inkey key.pem -in cert.pem -passout pass:secret -out default.p12
+//	openssl pkcs12 -export -inkey key.pem -in cert.pem -passout pass:secret -certpbe PBE-SHA1-3DES -keypbe PBE-SHA1-3DES -macalg sha1 -out legacy-3des.p12
+//	openssl pkcs12 -export -legacy -inkey key.pem -in cert.pem -passout pass:secret -out legacy-rc2.p12
+//	openssl pkcs12 -export -inkey key.pem -in cert.pem -passout pass: -out nopassword.p12
+const signingKeyTestData = "testdata/signingKey"
+
+func signingKeyTestFile(name string) string {
+	return filepath.Join(signingKeyTestData, name)
+}
+
+// readTestCertificate read cert.pem which every fixture must match.
+func readTestCertificate(t *testing.T) *x509.Certificate {
+	t.Helper()
+	data, err := ioutil.ReadFile(signingKeyTestFile("cert.pem"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, _ := pem.Decode(data)
+	certificate, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return certificate
+}
+
+var readSigningKeyTests = []struct {
+	name     string
+	keyFile  string
+	certFile string
+	password string
+	err      string // "" is success
+}{
+	{"PKCS#8 PEM", "key.pem", "cert.pem", "", ""},
+	{"PKCS#1 PEM", "key-pkcs1.pem", "cert.pem", "", ""},
+	{"encrypted PKCS#8 PEM", "key-encrypted.pem", "cert.pem", "secret", ""},
+	{"encrypted PKCS#8 PEM with wrong password", "key-encrypted.pem", "cert.pem", "wrong", "wrong password or corrupted key"},
+	{"legacy encrypted PEM", "key-legacy-encrypted.pem", "cert.pem", "secret", "legacy encrypted PEM key is not supported"},
+	{"PEM without key", "cert.pem", "", "", "no private key in PEM file"},
+	{"PEM key without certificate", "key.pem", "", "", "no certificate of private key"},
+	{"certificate file without certificate", "key.pem", "key-pkcs1.pem", "", "no certificate in PEM file"},
+	{"PKCS#12 of openssl 3 default, AES-256 and SHA-256 MAC", "default.p12", "", "secret", ""},
+	{"PKCS#12 of 3DES and SHA-1 MAC", "legacy-3des.p12", "", "secret", ""},
+	{"PKCS#12 with empty password", "nopassword.p12", "", "", ""},
+	{"PKCS#12 with wrong password", "default.p12", "", "wrong", "wrong password or corrupted key"},
+	{"PKCS#12 of 3DES with wrong password", "legacy-3des.p12", "", "wrong", "wrong password or corrupted key"},
+	{"PKCS#12 of openssl -legacy, RC2", "legacy-rc2.p12", "", "secret", "RC2 encrypted PKCS#12 is not supported"},
+	{"PEM read as PKCS#12", "key.p12", "", "", "not PKCS#12 file"},
+}
+
+func TestReadSigningKey(t *testing.T) {
+	certificate := readTestCertificate(t)
+	// PEM content under .p12 name is rejected as PKCS#12
+	data, err := ioutil.ReadFile(signingKeyTestFile("key.pem"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	if err := ioutil.WriteFile(filepath.Join(dir, "key.p12"), data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range readSigningKeyTests {
+		t.Run(test.name, func(t *testing.T) {
+			keyFile := signingKeyTestFile(test.keyFile)
+			if test.keyFile == "key.p12" {
+				keyFile = filepath.Join(dir, test.keyFile)
+			}
+			certFile := ""
+			if test.certFile != "" {
+				certFile = signingKeyTestFile(test.certFile)
+			}
+			key, err := ReadSigningKey(keyFile, certFile, test.password)
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("error %v, want %q", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(key.Certificates) == 0 || !key.Certificates[0].Equal(certificate) {
+				t.Fatalf("certificates %v, want cert.pem first", key.Certificates)
+			}
+			if !key.PrivateKey.PublicKey.Equal(certificate.PublicKey) {
+				t.Fatal("private key does not match cert.pem")
+			}
+		})
+	}
+}
+
+func TestReadSigningKeyPEMBundle(t *testing.T) {
+	certificate := readTestCertificate(t)
+	var bundle []byte
+	for _, name := range []string{"cert.pem", "key.pem"} {
+		data, err := ioutil.ReadFile(signingKeyTestFile(name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		bundle = append(bundle, data...)
+	}
+	dir := t.TempDir()
+	path := filepath.Join(dir, "bundle.pem")
+	if err := ioutil.WriteFile(path, bundle, 0600); err != nil {
+		t.Fatal(err)
+	}
+	key, err := ReadSigningKey(path, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(key.Certificates) != 1 || !key.Certificates[0].Equal(certificate) {
+		t.Fatalf("certificates %v, want cert.pem", key.Certificates)
+	}
+
+	// second key in same file is ambiguous
+	key2, err := ioutil.ReadFile(signingKeyTestFile("key-pkcs1.pem"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path, append(bundle, key2...), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReadSigningKey(path, "", ""); err == nil || !strings.Contains(err.Error(), "more than one private key") {
+		t.Fatalf("error %v, want more than one private key", err)
+	}
+}
+
+func TestReadSigningKeyPKCS12BadMAC(t *testing.T) {
+	for _, name := range []string{"default.p12", "legacy-3des.p12"} {
+		data, err := ioutil.ReadFile(signingKeyTestFile(name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		// byte in middle of file is encrypted content, changing it keep DER valid but break MAC
+		data[len(data)/2] ^= 0xff
+		path := filepath.Join(t.TempDir(), name)
+		if err := ioutil.WriteFile(path, data, 0600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := ReadSigningKey(path, "", "secret"); err == nil || !strings.Contains(err.Error(), "wrong password or corrupted key") {
+			t.Errorf("%s: error %v, want MAC failure", name, err)
+		}
+	}
+}
@@ -0,0 +1,362 @@
+// Package rdefiling read xml spec of Revenue Department e-Filing form and generate xsd, java parser and xml from frontend json.
+package rdefiling
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// XMLNameSpace is target namespace of every RD e-Filing form.
+const XMLNameSpace = "urn:schemas-rd-go-th:xml-services:common"
+
+// Field is one row of spec.
+type Field struct {
+	Index       string
+	FromKey     string
+	ToKey       string `json:",omitempty"`
+	Description string
+	Type        string
+	MaxLength   string
+	Multiple    string
+	Input       string
+	Output      string
+	Rules       []string `json:",omitempty"` // rule guideline codes such as Common_R11
+	Values      []string `json:",omitempty"` // allowed values from DEN of spec or code list
+	Pattern     string   `json:",omitempty"` // xsd pattern from code list
+	Line        int      `json:",omitempty"` // line of this field in spec file, for diagnostics
+
+	pattern *regexp.Regexp
+}
+
+// patternRegexp return compiled Pattern, nil when field has no valid pattern.
+func (f *Field) patternRegexp() *regexp.Regexp {
+	if f.pattern == nil && f.Pattern != "" {
+		f.pattern, _ = compilePattern(f.Pattern) // pattern is checked when code list is read
+	}
+	return f.pattern
+}
+
+// Spec is all used fields of a form in spec order.
+type Spec struct {
+	File           string // name of spec file, used in diagnostics
+	Fields         []Field
+	NotUsed        []Field // fields marked NU in Input column, kept for coverage report
+	UnusedMappings []string
+	ForceArray     map[string]bool
+	Overrides      *Overrides
+	Diagnostics    Diagnostics
+}
+
+// SpecOptions control how spec table is read.
+type SpecOptions struct {
+	Sheet         string // sheet name or 1-based sheet index of xlsx, default to first visible sheet
+	ContextLength int    // number of DEN columns, 0 means infer from header
+}
+
+func (s *Spec) errorf(stage string, field *Field, format string, a ...interface{}) {
+	s.Diagnostics.add(SeverityError, stage, s.File, field, format, a...)
+}
+
+func (s *Spec) warnf(stage string, field *Field, format string, a ...interface{}) {
+	s.Diagnostics.add(SeverityWarning, stage, s.File, field, format, a...)
+}
+
+// ReadSpec read spec file in csv or xlsx format depending on file extension.
+func ReadSpec(path string, options SpecOptions) (*Spec, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fatalError("spec", path, 0, "%v", err)
+	}
+	defer file.Close()
+	if strings.EqualFold(filepath.Ext(path), ".xlsx") {
+		info, err := file.Stat()
+		if err != nil {
+			return nil, fatalError("spec", path, 0, "%v", err)
+		}
+		return ReadSpecXLSX(file, info.Size(), path, options)
+	}
+	return ReadSpecCSV(file, path, options)
+}
+
+// ReadSpecCSV read spec exported from excel as csv, name is used in diagnostics.
+func ReadSpecCSV(reader io.Reader, name string, options SpecOptions) (*Spec, error) {
+	var records [][]string
+	var lines []int
+	csvReader := csv.NewReader(reader)
+	csvReader.FieldsPerRecord = -1
+	for {
+		record, err := csvReader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			line := 0
+			if parseErr, ok := err.(*csv.ParseError); ok {
+				line = parseErr.Line
+				err = parseErr.Err
+			}
+			return nil, fatalError("spec", name, line, "%v", err)
+		}
+		line, _ := csvReader.FieldPos(0)
+		records = append(records, record)
+		lines = append(lines, line)
+	}
+	return readSpecRecords(records, lines, name, options)
+}
+
+// ReadSpecXLSX read spec workbook published by RD, name is used in diagnostics.
+func ReadSpecXLSX(reader io.ReaderAt, size int64, name string, options SpecOptions) (*Spec, error) {
+	workbook, err := openXlsx(reader, size)
+	if err != nil {
+		return nil, fatalError("spec", name, 0, "%v", err)
+	}
+	sheet, err := workbook.FindSheet(options.Sheet)
+	if err != nil {
+		return nil, fatalError("spec", name, 0, "%v", err)
+	}
+	records, err := workbook.ReadRows(sheet)
+	if err != nil {
+		return nil, fatalError("spec", name, 0, "%v", err)
+	}
+	var lines []int
+	for i := range records {
+		lines = append(lines, i+1)
+	}
+	return readSpecRecords(records, lines, name, options)
+}
+
+// ReadSpecJSON read spec previously written by WriteJSON.
+func ReadSpecJSON(reader io.Reader, name string) (*Spec, error) {
+	data, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, fatalError("json", name, 0, "%v", err)
+	}
+	spec := &Spec{File: name, ForceArray: map[string]bool{}}
+	if err := json.Unmarshal(data, &spec.Fields); err != nil {
+		return nil, fatalError("json", name, jsonErrorLine(data, err), "can't read json spec: %v", err)
+	}
+	return spec, nil
+}
+
+// WriteJSON write fields of spec in json format.
+func (s *Spec) WriteJSON(writer io.Writer) error {
+	var buffer bytes.Buffer
+	encoder := json.NewEncoder(&buffer)
+	encoder.SetIndent("", "    ")
+	if err := encoder.Encode(s.Fields); err != nil {
+		return err
+	}
+	_, err := buffer.WriteTo(writer)
+	return err
+}
+
+type specColumns struct {
+	Index       int
+	DEN         int
+	XMLTag      int
+	Description int
+	Type        int
+	MaxLength   int
+	Multiple    int
+	Input       int
+	Output      int
+	Rule        int
+}
+
+var specColumnLabels = []struct {
+	Name     string
+	Labels   []string
+	Column   func(*specColumns) *int
+	Optional bool
+}{
+	{"Index", []string{"index"}, func(c *specColumns) *int { return &c.Index }, false},
+	{"Dictionary Entry Name (DEN)", []string{"dictionary entry name (den)", "dictionary entry name", "den"}, func(c *specColumns) *int { return &c.DEN }, false},
+	{"<XML Tag>", []string{"<xml tag>", "xml tag"}, func(c *specColumns) *int { return &c.XMLTag }, false},
+	{"Description", []string{"description"}, func(c *specColumns) *int { return &c.Description }, false},
+	{"Type", []string{"type"}, func(c *specColumns) *int { return &c.Type }, false},
+	{"Max Len", []string{"max len", "max length", "maxlen"}, func(c *specColumns) *int { return &c.MaxLength }, false},
+	{"Mult.", []string{"mult", "multiple", "multiplicity"}, func(c *specColumns) *int { return &c.Multiple }, false},
+	{"Input", []string{"input"}, func(c *specColumns) *int { return &c.Input }, false},
+	{"Output", []string{"output"}, func(c *specColumns) *int { return &c.Output }, true},
+	{"Rule Guideline", []string{"rule guideline", "rule guidelines"}, func(c *specColumns) *int { return &c.Rule }, true},
+}
+
+func normalizeSpecLabel(label string) string {
+	label = strings.ToLower(strings.Join(strings.Fields(strings.TrimPrefix(label, "\uFEFF")), " "))
+	return strings.TrimSuffix(label, ".")
+}
+
+func matchSpecColumns(columns *specColumns, record []string) {
+	for i, cell := range record {
+		label := normalizeSpecLabel(cell)
+		for _, l := range specColumnLabels {
+			column := l.Column(columns)
+			if *column >= 0 {
+				continue
+			}
+			for _, candidate := range l.Labels {
+				if label == candidate {
+					*column = i
+				}
+			}
+		}
+	}
+}
+
+// findSpecHeader locate header row by its labels. Header may span two rows, second row contains sub columns of Attribute (Mult., Input, Output).
+// It returns columns and index of first data row.
+func findSpecHeader(records [][]string, lines []int, name string) (specColumns, int, error) {
+	for i, record := range records {
+		var columns specColumns
+		for _, l := range specColumnLabels {
+			*l.Column(&columns) = -1
+		}
+		matchSpecColumns(&columns, record)
+		if columns.Index < 0 || columns.XMLTag < 0 {
+			continue
+		}
+		dataStart := i + 1
+		if dataStart < len(records) {
+			subColumns := columns
+			matchSpecColumns(&subColumns, records[dataStart])
+			if subColumns != columns && getCell(records[dataStart], columns.Index) == "" {
+				columns = subColumns
+				dataStart++
+			}
+		}
+		var missing []string
+		for _, l := range specColumnLabels {
+			if *l.Column(&columns) < 0 && !l.Optional {
+				missing = append(missing, l.Name)
+			}
+		}
+		if len(missing) > 0 {
+			return columns, 0, fatalError("spec", name, lines[i], "spec header missing column %s", strings.Join(missing, ", "))
+		}
+		if columns.DEN >= columns.XMLTag {
+			return columns, 0, fatalError("spec", name, lines[i], "DEN columns must be between Index and <XML Tag>")
+		}
+		return columns, dataStart, nil
+	}
+	return specColumns{}, 0, fatalError("spec", name, 0, "spec header not found, expect row with Index and <XML Tag> columns")
+}
+
+func readSpecRecords(records [][]string, lines []int, name string, options SpecOptions) (*Spec, error) {
+	columns, dataStart, err := findSpecHeader(records, lines, name)
+	if err != nil {
+		return nil, err
+	}
+	contextLength := columns.XMLTag - columns.DEN
+	if options.ContextLength != 0 && options.ContextLength != contextLength {
+		return nil, fatalError("spec", name, lines[dataStart-1], "specContextLength is %d but spec header has %d DEN columns", options.ContextLength, contextLength)
+	}
+	spec := &Spec{File: name, ForceArray: map[string]bool{}}
+	context := make([]string, contextLength)
+	for recordIndex, record := range records[dataStart:] {
+		index := getCell(record, columns.Index)
+		currentContext := 0
+		var values []string
+		for i := 0; i < contextLength; i++ {
+			if getCell(record, columns.DEN+i) != "" {
+				currentContext = i
+				values = parseDENValues(getCell(record, columns.DEN+i))
+				for j := range context[i:] {
+					context[i+j] = ""
+				}
+				break
+			}
+		}
+		context[currentContext] = stripTagRd(getCell(record, columns.XMLTag))
+		fromKey := joinStripEmpty(context)
+		description := getCell(record, columns.Description)
+		Type := normalizeSpecType(fromKey, strings.TrimSpace(getCell(record, columns.Type)))
+		max := strings.TrimSpace(getCell(record, columns.MaxLength))
+		multiple := strings.TrimSpace(getCell(record, columns.Multiple))
+		input := getCell(record, columns.Input)
+		output := strings.TrimSpace(getCell(record, columns.Output))
+		rules := parseRuleGuideline(getCell(record, columns.Rule))
+		isUsed := false
+		switch {
+		case Type == "": // Empty Row and table name
+			continue
+		case input == "NU": // Not used input
+		default:
+			isUsed = true
+		}
+		field := Field{
+			Description: description,
+			FromKey:     fromKey,
+			Index:       index,
+			MaxLength:   max,
+			Multiple:    multiple,
+			Type:        Type,
+			Input:       input,
+			Output:      output,
+			Rules:       rules,
+			Values:      values,
+			Line:        lines[dataStart+recordIndex],
+		}
+		if isUsed {
+			spec.Fields = append(spec.Fields, field)
+		} else {
+			spec.NotUsed = append(spec.NotUsed, field)
+		}
+	}
+	return spec, nil
+}
+
+// validSpecType report whether typ is a type known by generators.
+func validSpecType(typ string) bool {
+	switch typ {
+	case "String", "Number", "Boolean", "Date", "DateTime", "Time", "Year", "Object", "Array":
+		return true
+	}
+	_, _, ok := parseDecimalType(typ)
+	return ok
+}
+
+// ApplyTypes override Type of fields by FromKey, such as {"ExchangeDocument.IssueDateTime": "DateTime"}.
+// Only spelling of date and time types is unified, Date stays Date whatever element name is.
+func (s *Spec) ApplyTypes(types map[string]string) {
+	used := map[string]bool{}
+	for i := range s.Fields {
+		field := &s.Fields[i]
+		typ, ok := types[field.FromKey]
+		if !ok {
+			continue
+		}
+		used[field.FromKey] = true
+		typ = normalizeTypeSpelling(typ)
+		if !validSpecType(typ) {
+			s.errorf("types", field, "unknown type %q", typ)
+			continue
+		}
+		field.Type = typ
+	}
+	var unused []string
+	for key := range types {
+		if !used[key] {
+			unused = append(unused, key)
+		}
+	}
+	sort.Strings(unused)
+	if len(unused) > 0 {
+		s.warnf("types", nil, "type override match no field: %s", strings.Join(unused, ", "))
+	}
+}
+
+// parseRuleGuideline split rule guideline cell such as "Common_R11,Common_R23" into rule codes.
+func parseRuleGuideline(cell string) []string {
+	return strings.FieldsFunc(cell, func(r rune) bool {
+		return r == ',' || r == ';' || unicode.IsSpace(r)
+	})
+}
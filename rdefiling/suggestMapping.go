@@ -0,0 +1,446 @@
+package rdefiling
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Weights of signals making confidence of suggestion.
+const (
+	suggestNameWeight      = 0.5
+	suggestStructureWeight = 0.3
+	suggestTypeWeight      = 0.1
+	suggestShapeWeight     = 0.1
+	suggestStructureDepth  = 4   // number of parent segments compared
+	suggestParentConflict  = 0.5 // confidence is multiplied by it when path cross into other branch
+)
+
+// MappingSuggestion is proposed ToKey of field with its confidence and the score of each signal, between 0 and 1.
+type MappingSuggestion struct {
+	Index      string
+	FromKey    string
+	Type       string
+	ToKey      string
+	JSONType   string // object, array, string, number, boolean or null
+	Confidence float64
+	Name       float64 // similarity of last segment after lowerFirst
+	Structure  float64 // similarity of parent segments, nearer parent weight more
+	TypeMatch  float64 // type of spec against value of sample
+	Shape      float64 // both or neither repeated
+	Parent     float64 // 1 when path agree with objects suggested for parents, 0 when it cross into other branch
+	Current    string  `json:",omitempty"` // ToKey given by mapping of form
+}
+
+// jsonNode is object, array or value found in sample json. Page1 and page2 are left out of path
+// as getJSONByKey looks into them, items of array are merged into array node.
+type jsonNode struct {
+	path     string
+	segments []string
+	kind     string
+	value    interface{}
+	repeated bool // node is array or inside array
+	used     bool
+}
+
+// SuggestMapping propose ToKey of every field from paths of sample json, scoring name similarity after lowerFirst,
+// structural position, type compatibility and array shape. Each path is given to at most one field, best score first,
+// objects before values. Confidence is cut when path is outside object suggested for parent of field, or inside
+// object suggested for other branch. Suggestion below minConfidence is dropped. Suggestions are in spec order.
+func (s *Spec) SuggestMapping(sample io.Reader, sampleName string, minConfidence float64) ([]MappingSuggestion, error) {
+	data, err := ioutil.ReadAll(sample)
+	if err != nil {
+		return nil, fatalError("suggest", sampleName, 0, "%v", err)
+	}
+	var sampleValue interface{}
+	if err := json.Unmarshal(data, &sampleValue); err != nil {
+		return nil, fatalError("suggest", sampleName, jsonErrorLine(data, err), "invalid json: %v", err)
+	}
+	nodes := map[string]*jsonNode{}
+	var order []*jsonNode
+	collectJSONNodes(sampleValue, nil, false, nodes, &order)
+
+	fields := map[string]*Field{}
+	for i := range s.Fields {
+		fields[s.Fields[i].FromKey] = &s.Fields[i]
+	}
+	similarity := segmentSimilarity{}
+	var candidates []MappingSuggestion
+	fieldOrder := map[string]int{}
+	for i := range s.Fields {
+		field := &s.Fields[i]
+		segments := suggestSegments(field.FromKey, fields)
+		repeated := isRepeated(field.FromKey, fields)
+		for _, node := range order {
+			suggestion := MappingSuggestion{Index: field.Index, FromKey: field.FromKey, Type: field.Type, ToKey: node.path,
+				JSONType: node.kind, Current: field.ToKey}
+			if suggestion.Name = similarity.of(segments[len(segments)-1], node.segments[len(node.segments)-1]); suggestion.Name < 0.5 {
+				continue
+			}
+			if suggestion.TypeMatch = typeMatch(field.Type, node); suggestion.TypeMatch == 0 && (field.Type == "Object" ||
+				node.kind == "object" || node.kind == "array" && !isScalarArray(node)) {
+				continue
+			}
+			suggestion.Structure = similarity.ofParents(segments[:len(segments)-1], node.segments[:len(node.segments)-1])
+			if repeated == node.repeated {
+				suggestion.Shape = 1
+			}
+			suggestion.Confidence = round2(suggestNameWeight*suggestion.Name + suggestStructureWeight*suggestion.Structure +
+				suggestTypeWeight*suggestion.TypeMatch + suggestShapeWeight*suggestion.Shape)
+			if suggestion.Confidence < minConfidence {
+				continue
+			}
+			suggestion.Parent = 1
+			fieldOrder[field.FromKey] = i
+			candidates = append(candidates, suggestion)
+		}
+	}
+	// objects are assigned first, so that value whose path disagree with them is cut, such as EndDate of TaxPeriod
+	// found in object suggested for IncomeTaxExemption
+	var objects, values []MappingSuggestion
+	for _, candidate := range candidates {
+		if candidate.JSONType == "object" || candidate.JSONType == "array" && !isScalarArray(nodes[candidate.ToKey]) {
+			objects = append(objects, candidate)
+		} else {
+			values = append(values, candidate)
+		}
+	}
+	assigned := map[string]string{}
+	owners := map[string]string{}
+	suggestions := assignSuggestions(objects, nodes, assigned, owners, minConfidence)
+	suggestions = append(suggestions, assignSuggestions(values, nodes, assigned, owners, minConfidence)...)
+	sort.SliceStable(suggestions, func(i, j int) bool {
+		return fieldOrder[suggestions[i].FromKey] < fieldOrder[suggestions[j].FromKey]
+	})
+	return suggestions, nil
+}
+
+// assignSuggestions give each field and path to at most one suggestion, best confidence first. Suggestion
+// crossing into other branch than assigned before is cut and takes its turn again with lower confidence.
+func assignSuggestions(candidates []MappingSuggestion, nodes map[string]*jsonNode, assigned, owners map[string]string,
+	minConfidence float64) []MappingSuggestion {
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].Confidence > candidates[j].Confidence
+	})
+	var suggestions []MappingSuggestion
+	for len(candidates) > 0 {
+		candidate := candidates[0]
+		candidates = candidates[1:]
+		node := nodes[candidate.ToKey]
+		if _, ok := assigned[candidate.FromKey]; ok || node.used {
+			continue
+		}
+		if candidate.Parent == 1 && !parentCompatible(candidate.FromKey, candidate.ToKey, assigned, owners) {
+			candidate.Parent = 0
+			if candidate.Confidence = round2(candidate.Confidence * suggestParentConflict); candidate.Confidence >= minConfidence {
+				i := sort.Search(len(candidates), func(i int) bool { return candidates[i].Confidence < candidate.Confidence })
+				candidates = append(candidates[:i], append([]MappingSuggestion{candidate}, candidates[i:]...)...)
+			}
+			continue
+		}
+		assigned[candidate.FromKey], owners[candidate.ToKey], node.used = candidate.ToKey, candidate.FromKey, true
+		suggestions = append(suggestions, candidate)
+	}
+	return suggestions
+}
+
+// parentCompatible report whether path lies inside object suggested for the nearest parent of field which has one,
+// and whether object containing path, when suggested for a field, is suggested for a parent of field.
+func parentCompatible(fromKey, path string, assigned, owners map[string]string) bool {
+	for parent := fromKey; strings.Contains(parent, "."); {
+		parent = parent[:strings.LastIndexByte(parent, '.')]
+		if parentPath, ok := assigned[parent]; ok {
+			if !strings.HasPrefix(path, parentPath+".") {
+				return false
+			}
+			break
+		}
+	}
+	if dot := strings.LastIndexByte(path, '.'); dot >= 0 {
+		if owner, ok := owners[path[:dot]]; ok && !strings.HasPrefix(fromKey, owner+".") {
+			return false
+		}
+	}
+	return true
+}
+
+// DraftMapping make xmlNameMapping.json from suggestions, leaving out field whose ToKey follows from suggestion
+// of its parent object by lowerFirst.
+func DraftMapping(suggestions []MappingSuggestion) map[string]string {
+	toKeys := map[string]string{}
+	for _, suggestion := range suggestions {
+		toKeys[suggestion.FromKey] = suggestion.ToKey
+	}
+	draft := map[string]string{}
+	for _, suggestion := range suggestions {
+		if dot := strings.LastIndexByte(suggestion.FromKey, '.'); dot >= 0 {
+			parent, ok := toKeys[suggestion.FromKey[:dot]]
+			if ok && parent+"."+convertCase(suggestion.FromKey[dot+1:], CaseLowerFirst) == suggestion.ToKey {
+				continue
+			}
+		}
+		draft[suggestion.FromKey] = suggestion.ToKey
+	}
+	return draft
+}
+
+func collectJSONNodes(value interface{}, segments []string, repeated bool, nodes map[string]*jsonNode, order *[]*jsonNode) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if len(segments) > 0 {
+			addJSONNode(segments, "object", nil, repeated, nodes, order)
+		}
+		for _, key := range sortedKeys(v) {
+			if key == "page1" || key == "page2" {
+				collectJSONNodes(v[key], segments, repeated, nodes, order)
+			} else {
+				collectJSONNodes(v[key], append(segments[:len(segments):len(segments)], key), repeated, nodes, order)
+			}
+		}
+	case []interface{}:
+		if len(segments) == 0 {
+			return
+		}
+		node := addJSONNode(segments, "array", nil, true, nodes, order)
+		for _, item := range v {
+			if _, ok := item.(map[string]interface{}); ok {
+				collectJSONNodes(item, segments, true, nodes, order) // object item adds its keys to array node
+			} else if item != nil && node.value == nil {
+				node.value = item
+			}
+		}
+	default:
+		if len(segments) > 0 {
+			addJSONNode(segments, jsonKind(v), v, repeated, nodes, order)
+		}
+	}
+}
+
+func sortedKeys(object map[string]interface{}) []string {
+	keys := make([]string, 0, len(object))
+	for key := range object {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// addJSONNode add node or fill value of node seen before as null.
+func addJSONNode(segments []string, kind string, value interface{}, repeated bool, nodes map[string]*jsonNode, order *[]*jsonNode) *jsonNode {
+	path := strings.Join(segments, ".")
+	if node, ok := nodes[path]; ok {
+		if node.kind == "null" {
+			node.kind, node.value = kind, value
+		}
+		return node
+	}
+	node := &jsonNode{path: path, segments: segments, kind: kind, value: value, repeated: repeated}
+	nodes[path] = node
+	*order = append(*order, node)
+	return node
+}
+
+func jsonKind(value interface{}) string {
+	switch value.(type) {
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case bool:
+		return "boolean"
+	}
+	return "null"
+}
+
+func isScalarArray(node *jsonNode) bool {
+	return node.kind == "array" && node.value != nil
+}
+
+// suggestSegments split FromKey, repeated Detail becoming array is named by its parent, like ApplyMapping does.
+func suggestSegments(fromKey string, fields map[string]*Field) []string {
+	var segments []string
+	path := ""
+	for _, segment := range strings.Split(fromKey, ".") {
+		if path != "" {
+			path += "."
+		}
+		path += segment
+		if segment == "Detail" && len(segments) > 0 && isMultiple(fields[path]) {
+			continue
+		}
+		segments = append(segments, segment)
+	}
+	return segments
+}
+
+// isRepeated report whether field or any of its parents can occur more than once.
+func isRepeated(fromKey string, fields map[string]*Field) bool {
+	for path := fromKey; ; {
+		if isMultiple(fields[path]) {
+			return true
+		}
+		dot := strings.LastIndexByte(path, '.')
+		if dot < 0 {
+			return false
+		}
+		path = path[:dot]
+	}
+}
+
+func isMultiple(field *Field) bool {
+	if field == nil {
+		return false
+	}
+	_, max, ok := parseMultiple(field.Multiple)
+	return ok && (max < 0 || max > 1) || field.Type == "Array"
+}
+
+// typeMatch score type of field against value of sample, value seen only as null score half.
+func typeMatch(typ string, node *jsonNode) float64 {
+	kind, value := node.kind, node.value
+	if isScalarArray(node) {
+		kind = jsonKind(value)
+	}
+	if kind == "null" {
+		return 0.5
+	}
+	str, _ := value.(string)
+	switch {
+	case typ == "Object" || typ == "Array":
+		if kind == "object" || kind == "array" {
+			return 1
+		}
+	case kind == "object" || kind == "array":
+	case typ == "Number" || typ == "Year" || strings.HasPrefix(typ, "Decimal"):
+		if kind == "number" {
+			return 1
+		}
+		if _, err := strconv.ParseFloat(str, 64); err == nil && kind == "string" {
+			return 0.8
+		}
+	case typ == "Boolean":
+		switch {
+		case kind == "boolean":
+			return 1
+		case str == "true" || str == "false":
+			return 0.8
+		}
+	case typ == "Date" || typ == "DateTime":
+		if datePattern.MatchString(str) || dateTimePattern.MatchString(str) {
+			return 1
+		}
+		if kind == "string" {
+			return 0.3
+		}
+	case typ == "Time":
+		if timePattern.MatchString(str) {
+			return 1
+		}
+		if kind == "string" {
+			return 0.3
+		}
+	default:
+		switch kind {
+		case "string":
+			return 1
+		case "number":
+			return 0.6
+		case "boolean":
+			return 0.3
+		}
+	}
+	return 0
+}
+
+// segmentSimilarity cache similarity of segment pairs, the same names occur in many paths.
+type segmentSimilarity map[[2]string]float64
+
+// of score 1 when segment after lowerFirst or camelCase is the json key, otherwise share of common words,
+// word matching its abbreviation such as Ind and Indicator.
+func (c segmentSimilarity) of(segment, key string) float64 {
+	pair := [2]string{segment, key}
+	if score, ok := c[pair]; ok {
+		return score
+	}
+	score := 1.0
+	if convertCase(segment, CaseLowerFirst) != key && convertCase(segment, CaseCamel) != key && !strings.EqualFold(segment, key) {
+		score = 0.9 * wordSimilarity(splitWords(segment), splitWords(key))
+	}
+	c[pair] = score
+	return score
+}
+
+// ofParents compare parents from the nearest one, each one weight half of the one below it.
+func (c segmentSimilarity) ofParents(segments, keys []string) float64 {
+	if len(segments) == 0 {
+		if len(keys) <= 1 {
+			return 1
+		}
+		return 0.5
+	}
+	score, total, weight := 0.0, 0.0, 1.0
+	for i := 1; i <= suggestStructureDepth && i <= len(segments); i++ {
+		if i <= len(keys) {
+			score += weight * c.of(segments[len(segments)-i], keys[len(keys)-i])
+		}
+		total += weight
+		weight /= 2
+	}
+	return score / total
+}
+
+// splitWords split name into lower case words at under score, digits and upper case letters.
+func splitWords(name string) []string {
+	var words []string
+	var word []rune
+	runes := []rune(name)
+	for i, r := range runes {
+		if r == '_' || !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			if len(word) > 0 {
+				words, word = append(words, string(word)), nil
+			}
+			continue
+		}
+		if len(word) > 0 {
+			previous := runes[i-1]
+			if unicode.IsDigit(r) != unicode.IsDigit(previous) || unicode.IsUpper(r) &&
+				(unicode.IsLower(previous) || i+1 < len(runes) && unicode.IsLower(runes[i+1])) {
+				words, word = append(words, string(word)), nil
+			}
+		}
+		word = append(word, unicode.ToLower(r))
+	}
+	if len(word) > 0 {
+		words = append(words, string(word))
+	}
+	return words
+}
+
+// wordSimilarity is dice coefficient of words, word of 3 letters or more matches word starting with it.
+func wordSimilarity(a, b []string) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	matched := make([]bool, len(b))
+	common := 0
+	for _, word := range a {
+		for j, other := range b {
+			if !matched[j] && (word == other || len(word) >= 3 && len(other) >= 3 &&
+				(strings.HasPrefix(word, other) || strings.HasPrefix(other, word))) {
+				matched[j] = true
+				common++
+				break
+			}
+		}
+	}
+	return 2 * float64(common) / float64(len(a)+len(b))
+}
+
+func round2(value float64) float64 {
+	return math.Round(value*100) / 100
+}
@@ -0,0 +1,111 @@
+package rdefiling
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+// TestSuggestMappingFormSample suggest mapping of pnd50 from its testData.json and compare it with hand-written
+// xmlNameMapping.json, whose result is ToKey of fields.
+func TestSuggestMappingFormSample(t *testing.T) {
+	form, err := ReadForm(xsdGoldenForm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spec, err := form.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile(form.Path(form.TestData))
+	if err != nil {
+		t.Fatal(err)
+	}
+	suggestions, err := spec.SuggestMapping(strings.NewReader(string(data)), form.TestData, 0.6)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sample interface{}
+	if err := json.Unmarshal(data, &sample); err != nil {
+		t.Fatal(err)
+	}
+	nodes := map[string]*jsonNode{}
+	collectJSONNodes(sample, nil, false, nodes, &[]*jsonNode{})
+
+	owners := map[string]string{}
+	agreed := 0
+	for _, suggestion := range suggestions {
+		if suggestion.ToKey == suggestion.Current {
+			agreed++
+		} else if _, ok := nodes[suggestion.Current]; ok {
+			// hand-written ToKey is found in sample, suggestion should be the same
+			t.Errorf("%s suggested %s, mapping give %s", suggestion.FromKey, suggestion.ToKey, suggestion.Current)
+		}
+		if suggestion.JSONType == "object" {
+			owners[suggestion.ToKey] = suggestion.FromKey
+		}
+	}
+	if agreed < len(suggestions)*3/4 {
+		t.Errorf("%d of %d suggestions agree with mapping", agreed, len(suggestions))
+	}
+	for _, suggestion := range suggestions {
+		parent := suggestion.ToKey[:strings.LastIndexByte(suggestion.ToKey, '.')]
+		if owner, ok := owners[parent]; ok && !strings.HasPrefix(suggestion.FromKey, owner+".") {
+			t.Errorf("%s suggested %s inside object suggested for %s", suggestion.FromKey, suggestion.ToKey, owner)
+		}
+	}
+
+	file, err := os.Open(form.Path(form.XMLNameMapping))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	var mapping map[string]string
+	if err := json.NewDecoder(file).Decode(&mapping); err != nil {
+		t.Fatal(err)
+	}
+	for fromKey, toKey := range DraftMapping(suggestions) {
+		if written, ok := mapping[fromKey]; ok && written != toKey {
+			t.Errorf("draft map %s to %s, mapping to %s", fromKey, toKey, written)
+		}
+	}
+}
+
+func TestSuggestMappingParentConflict(t *testing.T) {
+	spec := &Spec{File: "spec.csv", Fields: []Field{
+		{FromKey: "TaxPayer", Type: "Object"},
+		{FromKey: "TaxPayer.Id13", Type: "String"},
+		{FromKey: "AuditorInfo", Type: "Object"},
+		{FromKey: "AuditorInfo.AuditorId13", Type: "String"},
+		{FromKey: "TaxPeriod", Type: "Object"},
+		{FromKey: "TaxPeriod.EndDate", Type: "Date"},
+		{FromKey: "IncomeTaxExemption", Type: "Object"},
+		{FromKey: "IncomeTaxExemption.StartDate", Type: "Date"},
+	}}
+	sample := `{"taxPayer": {"id13": "0105527003992", "auditorId13": "1234567890121"},
+		"incomeTaxExemption": {"startDate": "2021-02-08", "endDate": "2034-02-07"}}`
+	suggestions, err := spec.SuggestMapping(strings.NewReader(sample), "sample.json", 0.6)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]string{}
+	for _, suggestion := range suggestions {
+		got[suggestion.FromKey] = suggestion.ToKey
+	}
+	want := map[string]string{
+		"TaxPayer":                     "taxPayer",
+		"TaxPayer.Id13":                "taxPayer.id13",
+		"IncomeTaxExemption":           "incomeTaxExemption",
+		"IncomeTaxExemption.StartDate": "incomeTaxExemption.startDate",
+	}
+	if len(got) != len(want) {
+		t.Errorf("suggestions %v, want %v", got, want)
+	}
+	for fromKey, toKey := range want {
+		if got[fromKey] != toKey {
+			t.Errorf("%s suggested %q, want %q", fromKey, got[fromKey], toKey)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package rdefiling
+
+import (
+	"encoding/xml"
+	"io"
+	"strings"
+)
+
+// TestData write xml which every field of spec has dummy value and every array has two elements.
+func (s *Spec) TestData(writer io.Writer) error {
+	jsonInput := s.Fields
+
+	root := AnyXML{XMLName: xml.Name{Local: "rd:RdForm"}}
+	root.Attrs = append(root.Attrs, xml.Attr{Name: xml.Name{Local: "xmlns:rd"}, Value: XMLNameSpace})
+	var arrayFields []Field
+	setXMLValue := func(name string, value string) {
+		tokens := strings.Split(name, ".")
+		current := &root
+	tokensLoop:
+		for _, n := range tokens {
+			for i := range current.Nodes {
+				if current.Nodes[i].XMLName.Local[3:] == n {
+					current = &current.Nodes[i]
+					continue tokensLoop
+				}
+			}
+			current.Nodes = append(current.Nodes, AnyXML{
+				XMLName: xml.Name{Local: "rd:" + n},
+			})
+			current = &current.Nodes[len(current.Nodes)-1]
+		}
+		current.Data = value
+	}
+	duplicateXMLTag := func(field Field) {
+		tokens := strings.Split(field.FromKey, ".")
+		var parent *AnyXML
+		current := &root
+		currentIdx := 0
+	tokensLoop:
+		for _, n := range tokens {
+			for i := range current.Nodes {
+				if current.Nodes[i].XMLName.Local[3:] == n {
+					parent = current
+					currentIdx = i
+					current = &current.Nodes[i]
+					continue tokensLoop
+				}
+			}
+			s.errorf("testdata", &field, "can't duplicate array tag, array has no child element")
+			return
+		}
+		parent.Nodes = append(parent.Nodes[:currentIdx], append([]AnyXML{*current}, parent.Nodes[currentIdx:]...)...)
+	}
+
+	for _, field := range jsonInput {
+		if strings.HasPrefix(field.Type, "Decimal") {
+			field.Type = "Number"
+		}
+		if value := s.xmlValue(field.FromKey, nil); value != nil && field.Type != "Array" && field.Type != "Object" {
+			setXMLValue(field.FromKey, overrideText(value))
+			continue
+		}
+		if len(field.Values) > 0 && field.Type != "Array" && field.Type != "Object" {
+			setXMLValue(field.FromKey, field.Values[0])
+			continue
+		}
+		switch field.Type {
+		case "Array":
+			arrayFields = append(arrayFields, field)
+		case "Date":
+			setXMLValue(field.FromKey, "2020-01-02")
+		case "DateTime":
+			setXMLValue(field.FromKey, "2020-01-02T08:20:00+07:00")
+		case "Time":
+			setXMLValue(field.FromKey, "08:20:00+07:00")
+		case "Year":
+			setXMLValue(field.FromKey, "2020")
+		case "Number":
+			setXMLValue(field.FromKey, "8.20")
+		case "String":
+			setXMLValue(field.FromKey, "Test")
+		}
+	}
+	for _, field := range arrayFields {
+		duplicateXMLTag(field)
+	}
+	encoder := xml.NewEncoder(writer)
+	encoder.Indent("", "    ")
+	if err := encoder.Encode(root); err != nil {
+		return err
+	}
+	return encoder.Flush()
+}
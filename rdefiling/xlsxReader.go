@@ -0,0 +1,290 @@
+package rdefiling
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// minimal reader for office open xml spreadsheet (.xlsx), only support what is needed to read spec workbook.
+
+type xlsxSheet struct {
+	Name   string
+	Hidden bool
+	Path   string
+}
+
+type xlsxWorkbook struct {
+	files         map[string]*zip.File
+	sharedStrings []string
+	Sheets        []xlsxSheet
+}
+
+func openXlsx(reader io.ReaderAt, size int64) (*xlsxWorkbook, error) {
+	zipReader, err := zip.NewReader(reader, size)
+	if err != nil {
+		return nil, err
+	}
+	wb := &xlsxWorkbook{files: map[string]*zip.File{}}
+	for _, f := range zipReader.File {
+		wb.files[f.Name] = f
+	}
+	if err := wb.readSheetList(); err != nil {
+		return nil, err
+	}
+	if err := wb.readSharedStrings(); err != nil {
+		return nil, err
+	}
+	return wb, nil
+}
+
+func (wb *xlsxWorkbook) decodeFile(name string, v interface{}) error {
+	f, ok := wb.files[name]
+	if !ok {
+		return fmt.Errorf("xlsx: missing %s", name)
+	}
+	reader, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	if err := xml.NewDecoder(reader).Decode(v); err != nil {
+		return fmt.Errorf("xlsx: %s: %v", name, err)
+	}
+	return nil
+}
+
+func (wb *xlsxWorkbook) readSheetList() error {
+	var workbook struct {
+		Sheets []struct {
+			Name  string `xml:"name,attr"`
+			State string `xml:"state,attr"`
+			ID    string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+		} `xml:"sheets>sheet"`
+	}
+	if err := wb.decodeFile("xl/workbook.xml", &workbook); err != nil {
+		return err
+	}
+	var rels struct {
+		Relationships []struct {
+			ID     string `xml:"Id,attr"`
+			Target string `xml:"Target,attr"`
+		} `xml:"Relationship"`
+	}
+	if err := wb.decodeFile("xl/_rels/workbook.xml.rels", &rels); err != nil {
+		return err
+	}
+	targets := map[string]string{}
+	for _, rel := range rels.Relationships {
+		target := rel.Target
+		if strings.HasPrefix(target, "/") {
+			target = target[1:]
+		} else {
+			target = path.Join("xl", target)
+		}
+		targets[rel.ID] = target
+	}
+	for _, sheet := range workbook.Sheets {
+		target, ok := targets[sheet.ID]
+		if !ok {
+			return fmt.Errorf("xlsx: no relationship for sheet %q", sheet.Name)
+		}
+		wb.Sheets = append(wb.Sheets, xlsxSheet{
+			Name:   sheet.Name,
+			Hidden: sheet.State != "" && sheet.State != "visible",
+			Path:   target,
+		})
+	}
+	if len(wb.Sheets) == 0 {
+		return errors.New("xlsx: workbook has no sheet")
+	}
+	return nil
+}
+
+func (wb *xlsxWorkbook) readSharedStrings() error {
+	if _, ok := wb.files["xl/sharedStrings.xml"]; !ok {
+		return nil
+	}
+	var sst struct {
+		Items []struct {
+			T    string `xml:"t"`
+			Runs []struct {
+				T string `xml:"t"`
+			} `xml:"r"`
+		} `xml:"si"`
+	}
+	if err := wb.decodeFile("xl/sharedStrings.xml", &sst); err != nil {
+		return err
+	}
+	for _, item := range sst.Items {
+		str := item.T
+		for _, run := range item.Runs { // rich text, ignore formatting
+			str += run.T
+		}
+		wb.sharedStrings = append(wb.sharedStrings, str)
+	}
+	return nil
+}
+
+// FindSheet find sheet by name, or by 1-based position when name is a number.
+// Empty name select first visible sheet.
+func (wb *xlsxWorkbook) FindSheet(name string) (xlsxSheet, error) {
+	if name == "" {
+		for _, sheet := range wb.Sheets {
+			if !sheet.Hidden {
+				return sheet, nil
+			}
+		}
+		return wb.Sheets[0], nil
+	}
+	for _, sheet := range wb.Sheets {
+		if sheet.Name == name {
+			return sheet, nil
+		}
+	}
+	if index, err := strconv.Atoi(name); err == nil {
+		if index < 1 || index > len(wb.Sheets) {
+			return xlsxSheet{}, fmt.Errorf("xlsx: sheet index %d out of range 1-%d", index, len(wb.Sheets))
+		}
+		return wb.Sheets[index-1], nil
+	}
+	var names []string
+	for _, sheet := range wb.Sheets {
+		names = append(names, strconv.Quote(sheet.Name))
+	}
+	return xlsxSheet{}, fmt.Errorf("xlsx: sheet %q not found, available sheets are %s", name, strings.Join(names, ", "))
+}
+
+// ReadRows read all rows of sheet, every row is padded to the same width like csv.
+func (wb *xlsxWorkbook) ReadRows(sheet xlsxSheet) ([][]string, error) {
+	f, ok := wb.files[sheet.Path]
+	if !ok {
+		return nil, fmt.Errorf("xlsx: missing %s", sheet.Path)
+	}
+	reader, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	type cell struct {
+		Ref       string `xml:"r,attr"`
+		Type      string `xml:"t,attr"`
+		Value     string `xml:"v"`
+		InlineStr struct {
+			T    string `xml:"t"`
+			Runs []struct {
+				T string `xml:"t"`
+			} `xml:"r"`
+		} `xml:"is"`
+	}
+	var rows [][]string
+	width := 0
+	decoder := xml.NewDecoder(reader)
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("xlsx: %s: %v", sheet.Path, err)
+		}
+		start, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		switch start.Name.Local {
+		case "row":
+			rowNumber := len(rows) + 1
+			for _, attr := range start.Attr {
+				if attr.Name.Local == "r" {
+					if n, err := strconv.Atoi(attr.Value); err == nil {
+						rowNumber = n
+					}
+				}
+			}
+			for len(rows) < rowNumber { // missing row in sheet xml is empty row
+				rows = append(rows, nil)
+			}
+		case "c":
+			if len(rows) == 0 {
+				rows = append(rows, nil)
+			}
+			var c cell
+			if err := decoder.DecodeElement(&c, &start); err != nil {
+				return nil, fmt.Errorf("xlsx: %s: %v", sheet.Path, err)
+			}
+			row := &rows[len(rows)-1]
+			column := len(*row)
+			if c.Ref != "" {
+				column, err = xlsxColumnIndex(c.Ref)
+				if err != nil {
+					return nil, err
+				}
+			}
+			for len(*row) <= column {
+				*row = append(*row, "")
+			}
+			value := c.Value
+			switch c.Type {
+			case "s":
+				index, err := strconv.Atoi(c.Value)
+				if err != nil || index < 0 || index >= len(wb.sharedStrings) {
+					return nil, fmt.Errorf("xlsx: %s: invalid shared string index %q in cell %s", sheet.Path, c.Value, c.Ref)
+				}
+				value = wb.sharedStrings[index]
+			case "inlineStr":
+				value = c.InlineStr.T
+				for _, run := range c.InlineStr.Runs {
+					value += run.T
+				}
+			case "b":
+				if value == "1" {
+					value = "TRUE"
+				} else {
+					value = "FALSE"
+				}
+			case "", "n":
+				value = xlsxFormatNumber(value)
+			}
+			(*row)[column] = value
+			if len(*row) > width {
+				width = len(*row)
+			}
+		}
+	}
+	for i := range rows {
+		for len(rows[i]) < width {
+			rows[i] = append(rows[i], "")
+		}
+	}
+	return rows, nil
+}
+
+// xlsxColumnIndex convert cell reference such as "AB12" to zero based column index.
+func xlsxColumnIndex(ref string) (int, error) {
+	column := 0
+	i := 0
+	for ; i < len(ref) && ref[i] >= 'A' && ref[i] <= 'Z'; i++ {
+		column = column*26 + int(ref[i]-'A'+1)
+	}
+	if i == 0 {
+		return 0, fmt.Errorf("xlsx: invalid cell reference %q", ref)
+	}
+	return column - 1, nil
+}
+
+// xlsxFormatNumber print number the way excel show in general format, "1.1000000000000001" become "1.1".
+func xlsxFormatNumber(value string) string {
+	v, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return value
+	}
+	v, _ = strconv.ParseFloat(strconv.FormatFloat(v, 'g', 15, 64), 64)
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
@@ -0,0 +1,371 @@
+package rdefiling
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"tnd/pkg/encoding/strictxml"
+)
+
+// Algorithm identifiers of XML-DSig used by SignXML, VerifyXML also accept SHA-1 and SHA-512 variants.
+const (
+	algorithmExcC14N             = "http://www.w3.org/2001/10/xml-exc-c14n#"
+	algorithmExcC14NWithComments = "http://www.w3.org/2001/10/xml-exc-c14n#WithComments"
+	algorithmEnvelopedSignature  = "http://www.w3.org/2000/09/xmldsig#enveloped-signature"
+	algorithmSHA256              = "http://www.w3.org/2001/04/xmlenc#sha256"
+	algorithmRSASHA256           = "http://www.w3.org/2001/04/xmldsig-more#rsa-sha256"
+)
+
+var digestAlgorithms = map[string]crypto.Hash{
+	"http://www.w3.org/2000/09/xmldsig#sha1":  crypto.SHA1,
+	algorithmSHA256:                           crypto.SHA256,
+	"http://www.w3.org/2001/04/xmlenc#sha512": crypto.SHA512,
+}
+
+var signatureAlgorithms = map[string]crypto.Hash{
+	"http://www.w3.org/2000/09/xmldsig#rsa-sha1":        crypto.SHA1,
+	algorithmRSASHA256:                                  crypto.SHA256,
+	"http://www.w3.org/2001/04/xmldsig-more#rsa-sha512": crypto.SHA512,
+}
+
+// canonicalize return exclusive canonical form of node, writing to buffer never fail.
+func canonicalize(node *strictxml.Node, options strictxml.C14NOptions) []byte {
+	var buffer bytes.Buffer
+	node.Canonicalize(&buffer, options)
+	return buffer.Bytes()
+}
+
+// inclusivePrefixes read PrefixList of ec:InclusiveNamespaces parameter of canonicalization method or transform.
+func inclusivePrefixes(node *strictxml.Node) ([]string, string) {
+	var prefixes []string
+	for _, parameter := range node.Children {
+		if parameter.Kind != strictxml.ElementNode {
+			continue
+		}
+		if !parameter.Is(algorithmExcC14N, "InclusiveNamespaces") {
+			return nil, "parameter " + parameter.Local + " of canonicalization is not supported"
+		}
+		for _, attr := range parameter.Attrs {
+			if attr.Name == "PrefixList" {
+				prefixes = append(prefixes, strings.Fields(attr.Value)...)
+			}
+		}
+	}
+	return prefixes, ""
+}
+
+func digest(hash crypto.Hash, data []byte) []byte {
+	h := hash.New()
+	h.Write(data)
+	return h.Sum(nil)
+}
+
+// decodeBase64 decode base64 content of element, line breaks put by other signers are ignored.
+func decodeBase64(str string) ([]byte, error) {
+	return base64.StdEncoding.DecodeString(strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) {
+			return -1
+		}
+		return r
+	}, str))
+}
+
+// SignXML put enveloped ds:Signature as last child of root element, signed by RSA-SHA256 over exclusive canonical form of whole document.
+// Rest of document is copied byte by byte, so xml from JSONToXML keep its format.
+func SignXML(reader io.Reader, name string, writer io.Writer, key *SigningKey) error {
+	data, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return fatalError("sign", name, 0, "%v", err)
+	}
+	document, err := strictxml.Parse(bytes.NewReader(data))
+	if err != nil {
+		return fatalError("sign", name, xmlErrorLine(err), "can't parse xml: %v", err)
+	}
+	root := document.Root()
+	if root.End < 0 {
+		return fatalError("sign", name, root.Line, "root element %s is written as empty element, signature can't be put into it", root.Local)
+	}
+	if signature := root.Child(XMLDSigNameSpace, "Signature"); signature != nil {
+		return fatalError("sign", name, signature.Line, "document is already signed")
+	}
+
+	signedInfo := `<ds:SignedInfo>` +
+		`<ds:CanonicalizationMethod Algorithm="` + algorithmExcC14N + `"></ds:CanonicalizationMethod>` +
+		`<ds:SignatureMethod Algorithm="` + algorithmRSASHA256 + `"></ds:SignatureMethod>` +
+		`<ds:Reference URI=""><ds:Transforms>` +
+		`<ds:Transform Algorithm="` + algorithmEnvelopedSignature + `"></ds:Transform>` +
+		`<ds:Transform Algorithm="` + algorithmExcC14N + `"></ds:Transform>` +
+		`</ds:Transforms>` +
+		`<ds:DigestMethod Algorithm="` + algorithmSHA256 + `"></ds:DigestMethod>` +
+		`<ds:DigestValue>` + base64.StdEncoding.EncodeToString(digest(crypto.SHA256, canonicalize(document, strictxml.C14NOptions{}))) + `</ds:DigestValue>` +
+		`</ds:Reference></ds:SignedInfo>`
+	// SignedInfo is canonicalized as it is inside Signature, exclusive canonicalization does not depend on namespaces of document
+	signatureDocument, err := strictxml.Parse(strings.NewReader(`<ds:Signature xmlns:ds="` + XMLDSigNameSpace + `">` + signedInfo + `</ds:Signature>`))
+	if err != nil {
+		return fatalError("sign", name, 0, "can't parse SignedInfo: %v", err)
+	}
+	canonicalSignedInfo := canonicalize(signatureDocument.Root().Child(XMLDSigNameSpace, "SignedInfo"), strictxml.C14NOptions{})
+	value, err := rsa.SignPKCS1v15(rand.Reader, key.PrivateKey, crypto.SHA256, digest(crypto.SHA256, canonicalSignedInfo))
+	if err != nil {
+		return fatalError("sign", name, 0, "%v", err)
+	}
+	signature := `<ds:Signature xmlns:ds="` + XMLDSigNameSpace + `">` + signedInfo +
+		`<ds:SignatureValue>` + base64.StdEncoding.EncodeToString(value) + `</ds:SignatureValue>` +
+		`<ds:KeyInfo><ds:X509Data>`
+	for _, certificate := range key.Certificates {
+		signature += `<ds:X509Certificate>` + base64.StdEncoding.EncodeToString(certificate.Raw) + `</ds:X509Certificate>`
+	}
+	signature += `</ds:X509Data></ds:KeyInfo></ds:Signature>`
+
+	for _, part := range [][]byte{data[:root.End], []byte(signature), data[root.End:]} {
+		if _, err := writer.Write(part); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// VerifyXML check every ds:Signature of xml document and return certificate of each valid signature.
+// Signature is trusted when its certificate is one of trusted or issued by one of them. Without trusted certificates
+// only integrity is checked with certificate in KeyInfo and warning is reported.
+func VerifyXML(reader io.Reader, name string, trusted []*x509.Certificate) ([]*x509.Certificate, Diagnostics, error) {
+	document, err := strictxml.Parse(reader)
+	if err != nil {
+		return nil, nil, fatalError("verify", name, xmlErrorLine(err), "can't parse xml: %v", err)
+	}
+	var signatures []*strictxml.Node
+	var walk func(*strictxml.Node)
+	walk = func(node *strictxml.Node) {
+		for _, child := range node.Children {
+			if child.Is(XMLDSigNameSpace, "Signature") {
+				signatures = append(signatures, child)
+			} else if child.Kind == strictxml.ElementNode {
+				walk(child)
+			}
+		}
+	}
+	walk(document)
+	if len(signatures) == 0 {
+		return nil, nil, fatalError("verify", name, 0, "no ds:Signature in document")
+	}
+	var signers []*x509.Certificate
+	var diagnostics Diagnostics
+	for _, signature := range signatures {
+		signer, covered, message := verifySignature(document, signature, trusted)
+		if message != "" {
+			diagnostics = append(diagnostics, Diagnostic{Severity: SeverityError, Stage: "verify", File: name, Line: signature.Line, Message: message})
+			continue
+		}
+		if covered[0] != document {
+			var elements []string
+			for _, node := range covered {
+				elements = append(elements, node.Local+" at line "+strconv.Itoa(node.Line))
+			}
+			diagnostics = append(diagnostics, Diagnostic{Severity: SeverityWarning, Stage: "verify", File: name, Line: signature.Line,
+				Message: "signature cover only " + strings.Join(elements, ", ") + ", rest of document is not signed"})
+		}
+		if len(trusted) == 0 {
+			diagnostics = append(diagnostics, Diagnostic{Severity: SeverityWarning, Stage: "verify", File: name, Line: signature.Line,
+				Message: "certificate " + signer.Subject.String() + " is not checked, no trusted certificate given"})
+		}
+		signers = append(signers, signer)
+	}
+	return signers, diagnostics, nil
+}
+
+// verifySignature check references and signature value of signature, message describe the first problem found.
+// Covered are nodes signed by references, document itself when whole document is signed.
+// Signature of root element must sign whole document, otherwise signed element could be moved into wrapper
+// and document around it changed freely.
+func verifySignature(document, signature *strictxml.Node, trusted []*x509.Certificate) (*x509.Certificate, []*strictxml.Node, string) {
+	signedInfo := signature.Child(XMLDSigNameSpace, "SignedInfo")
+	if signedInfo == nil {
+		return nil, nil, "ds:Signature has no ds:SignedInfo"
+	}
+	algorithm := func(node *strictxml.Node, local string) string {
+		if child := node.Child(XMLDSigNameSpace, local); child != nil {
+			value, _ := child.Attr("Algorithm")
+			return value
+		}
+		return ""
+	}
+	canonicalization := algorithm(signedInfo, "CanonicalizationMethod")
+	if canonicalization != algorithmExcC14N && canonicalization != algorithmExcC14NWithComments {
+		return nil, nil, "canonicalization " + canonicalization + " is not supported"
+	}
+	prefixes, message := inclusivePrefixes(signedInfo.Child(XMLDSigNameSpace, "CanonicalizationMethod"))
+	if message != "" {
+		return nil, nil, message + " of ds:SignedInfo"
+	}
+	signatureHash, ok := signatureAlgorithms[algorithm(signedInfo, "SignatureMethod")]
+	if !ok {
+		return nil, nil, "signature method " + algorithm(signedInfo, "SignatureMethod") + " is not supported"
+	}
+
+	var covered []*strictxml.Node
+	references := 0
+	for _, reference := range signedInfo.Children {
+		if !reference.Is(XMLDSigNameSpace, "Reference") {
+			continue
+		}
+		references++
+		uri, _ := reference.Attr("URI")
+		label := "reference " + strconv.Quote(uri)
+		target := document
+		if uri != "" {
+			targets := findElementsByID(document, strings.TrimPrefix(uri, "#"))
+			if !strings.HasPrefix(uri, "#") || len(targets) == 0 {
+				return nil, nil, label + " is not found"
+			}
+			if len(targets) > 1 {
+				var lines []string
+				for _, target := range targets {
+					lines = append(lines, strconv.Itoa(target.Line))
+				}
+				return nil, nil, label + " is ambiguous, id is used by elements at line " + strings.Join(lines, ", ")
+			}
+			target = targets[0]
+		}
+		if target == document {
+			covered = append([]*strictxml.Node{document}, covered...)
+		} else {
+			covered = append(covered, target)
+		}
+		var exclude *strictxml.Node
+		canonical := false
+		var referencePrefixes []string
+		if transforms := reference.Child(XMLDSigNameSpace, "Transforms"); transforms != nil {
+			for _, transform := range transforms.Children {
+				if !transform.Is(XMLDSigNameSpace, "Transform") {
+					continue
+				}
+				switch value, _ := transform.Attr("Algorithm"); value {
+				case algorithmEnvelopedSignature:
+					exclude = signature
+				case algorithmExcC14N, algorithmExcC14NWithComments:
+					var message string
+					if referencePrefixes, message = inclusivePrefixes(transform); message != "" {
+						return nil, nil, message + " of " + label
+					}
+					canonical = true
+				default:
+					return nil, nil, "transform " + value + " of " + label + " is not supported"
+				}
+			}
+		}
+		if !canonical {
+			return nil, nil, label + " without exclusive canonicalization is not supported"
+		}
+		digestHash, ok := digestAlgorithms[algorithm(reference, "DigestMethod")]
+		if !ok {
+			return nil, nil, "digest method " + algorithm(reference, "DigestMethod") + " is not supported"
+		}
+		digestValue := reference.Child(XMLDSigNameSpace, "DigestValue")
+		if digestValue == nil {
+			return nil, nil, label + " has no ds:DigestValue"
+		}
+		expected, err := decodeBase64(digestValue.Value())
+		if err != nil {
+			return nil, nil, "ds:DigestValue is not base64: " + err.Error()
+		}
+		// same document reference never include comments, even with #WithComments
+		if !bytes.Equal(digest(digestHash, canonicalize(target, strictxml.C14NOptions{InclusivePrefixes: referencePrefixes, Exclude: exclude})), expected) {
+			return nil, nil, "digest of " + label + " does not match, document is changed after signing"
+		}
+	}
+	if references == 0 {
+		return nil, nil, "ds:SignedInfo has no ds:Reference"
+	}
+	if signature.Parent == document.Root() && covered[0] != document {
+		return nil, nil, "signature of " + signature.Parent.Local + ` must sign whole document by reference URI=""`
+	}
+
+	signatureValue := signature.Child(XMLDSigNameSpace, "SignatureValue")
+	if signatureValue == nil {
+		return nil, nil, "ds:Signature has no ds:SignatureValue"
+	}
+	value, err := decodeBase64(signatureValue.Value())
+	if err != nil {
+		return nil, nil, "ds:SignatureValue is not base64: " + err.Error()
+	}
+	var certificates []*x509.Certificate
+	if keyInfo := signature.Child(XMLDSigNameSpace, "KeyInfo"); keyInfo != nil {
+		for _, x509Data := range keyInfo.Children {
+			if !x509Data.Is(XMLDSigNameSpace, "X509Data") {
+				continue
+			}
+			for _, child := range x509Data.Children {
+				if !child.Is(XMLDSigNameSpace, "X509Certificate") {
+					continue
+				}
+				der, err := decodeBase64(child.Value())
+				if err != nil {
+					return nil, nil, "ds:X509Certificate is not base64: " + err.Error()
+				}
+				certificate, err := x509.ParseCertificate(der)
+				if err != nil {
+					return nil, nil, "ds:X509Certificate: " + err.Error()
+				}
+				certificates = append(certificates, certificate)
+			}
+		}
+	}
+	if len(certificates) == 0 {
+		certificates = trusted
+	}
+	hashed := digest(signatureHash, canonicalize(signedInfo, strictxml.C14NOptions{WithComments: canonicalization == algorithmExcC14NWithComments, InclusivePrefixes: prefixes}))
+	var signer *x509.Certificate
+	for _, certificate := range certificates {
+		if publicKey, ok := certificate.PublicKey.(*rsa.PublicKey); ok && rsa.VerifyPKCS1v15(publicKey, signatureHash, hashed, value) == nil {
+			signer = certificate
+			break
+		}
+	}
+	if signer == nil {
+		return nil, nil, "signature value does not match any certificate"
+	}
+	if len(trusted) == 0 {
+		return signer, covered, ""
+	}
+	roots, intermediates := x509.NewCertPool(), x509.NewCertPool()
+	for _, certificate := range trusted {
+		if certificate.Equal(signer) {
+			return signer, covered, ""
+		}
+		roots.AddCert(certificate)
+	}
+	for _, certificate := range certificates {
+		intermediates.AddCert(certificate)
+	}
+	if _, err := signer.Verify(x509.VerifyOptions{Roots: roots, Intermediates: intermediates, KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageAny}}); err != nil {
+		return nil, nil, "certificate " + signer.Subject.String() + " is not trusted: " + err.Error()
+	}
+	return signer, covered, ""
+}
+
+// findElementsByID find every element with Id, ID or id attribute equal to id, valid document has at most one.
+func findElementsByID(node *strictxml.Node, id string) []*strictxml.Node {
+	var found []*strictxml.Node
+	for _, child := range node.Children {
+		if child.Kind != strictxml.ElementNode {
+			continue
+		}
+		for _, attr := range []string{"Id", "ID", "id"} {
+			if value, ok := child.Attr(attr); ok && value == id {
+				found = append(found, child)
+				break
+			}
+		}
+		found = append(found, findElementsByID(child, id)...)
+	}
+	return found
+}
@@ -0,0 +1,202 @@
+package rdefiling
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"tnd/pkg/encoding/strictxml"
+)
+
+const signatureTestDocument = `<?xml version="1.0" encoding="UTF-8"?>
+<rd:RdForm xmlns:rd="urn:schemas-rd-go-th:xml-services:common">
+	<rd:TaxPayer Id="tp">
+		<rd:Name>Somchai</rd:Name>
+	</rd:TaxPayer>
+	<rd:Total>100.00</rd:Total>
+</rd:RdForm>
+`
+
+// newTestSigningKey make RSA key with self-signed certificate.
+func newTestSigningKey(t *testing.T, name string) *SigningKey {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certificate, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &SigningKey{PrivateKey: key, Certificates: []*x509.Certificate{certificate}}
+}
+
+func signTestDocument(t *testing.T, key *SigningKey, document string) string {
+	t.Helper()
+	var output bytes.Buffer
+	if err := SignXML(strings.NewReader(document), "test.xml", &output, key); err != nil {
+		t.Fatal(err)
+	}
+	return output.String()
+}
+
+// signTestReference make detached ds:Signature of element with id, as other signers do.
+func signTestReference(t *testing.T, key *SigningKey, document string, id string) string {
+	t.Helper()
+	parsed, err := strictxml.Parse(strings.NewReader(document))
+	if err != nil {
+		t.Fatal(err)
+	}
+	targets := findElementsByID(parsed, id)
+	if len(targets) != 1 {
+		t.Fatalf("%d elements with id %s", len(targets), id)
+	}
+	signedInfo := `<ds:SignedInfo>` +
+		`<ds:CanonicalizationMethod Algorithm="` + algorithmExcC14N + `"></ds:CanonicalizationMethod>` +
+		`<ds:SignatureMethod Algorithm="` + algorithmRSASHA256 + `"></ds:SignatureMethod>` +
+		`<ds:Reference URI="#` + id + `"><ds:Transforms>` +
+		`<ds:Transform Algorithm="` + algorithmExcC14N + `"></ds:Transform>` +
+		`</ds:Transforms>` +
+		`<ds:DigestMethod Algorithm="` + algorithmSHA256 + `"></ds:DigestMethod>` +
+		`<ds:DigestValue>` + base64.StdEncoding.EncodeToString(digest(crypto.SHA256, canonicalize(targets[0], strictxml.C14NOptions{}))) + `</ds:DigestValue>` +
+		`</ds:Reference></ds:SignedInfo>`
+	signature, err := strictxml.Parse(strings.NewReader(`<ds:Signature xmlns:ds="` + XMLDSigNameSpace + `">` + signedInfo + `</ds:Signature>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	hashed := digest(crypto.SHA256, canonicalize(signature.Root().Child(XMLDSigNameSpace, "SignedInfo"), strictxml.C14NOptions{}))
+	value, err := rsa.SignPKCS1v15(rand.Reader, key.PrivateKey, crypto.SHA256, hashed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return `<ds:Signature xmlns:ds="` + XMLDSigNameSpace + `">` + signedInfo +
+		`<ds:SignatureValue>` + base64.StdEncoding.EncodeToString(value) + `</ds:SignatureValue>` +
+		`<ds:KeyInfo><ds:X509Data><ds:X509Certificate>` + base64.StdEncoding.EncodeToString(key.Certificates[0].Raw) +
+		`</ds:X509Certificate></ds:X509Data></ds:KeyInfo></ds:Signature>`
+}
+
+// verifyTestDocument verify document trusting key and return signers and messages of diagnostics.
+func verifyTestDocument(t *testing.T, key *SigningKey, document string) ([]*x509.Certificate, []string) {
+	t.Helper()
+	signers, diagnostics, err := VerifyXML(strings.NewReader(document), "test.xml", key.Certificates)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var messages []string
+	for _, d := range diagnostics {
+		messages = append(messages, d.Severity+": "+d.Message)
+	}
+	return signers, messages
+}
+
+func expectMessage(t *testing.T, messages []string, want string) {
+	t.Helper()
+	if len(messages) != 1 || !strings.Contains(messages[0], want) {
+		t.Fatalf("diagnostics %q, want one containing %q", messages, want)
+	}
+}
+
+func TestSignVerify(t *testing.T) {
+	key := newTestSigningKey(t, "signer")
+	signed := signTestDocument(t, key, signatureTestDocument)
+	signers, messages := verifyTestDocument(t, key, signed)
+	if len(messages) != 0 {
+		t.Fatalf("unexpected diagnostics %q", messages)
+	}
+	if len(signers) != 1 || !signers[0].Equal(key.Certificates[0]) {
+		t.Fatalf("signers %v, want certificate of key", signers)
+	}
+	if !strings.HasPrefix(signed, signatureTestDocument[:strings.Index(signatureTestDocument, "</rd:RdForm>")]) {
+		t.Error("document is not copied byte by byte before signature")
+	}
+}
+
+func TestVerifyUntrusted(t *testing.T) {
+	key := newTestSigningKey(t, "signer")
+	other := newTestSigningKey(t, "other")
+	signed := signTestDocument(t, key, signatureTestDocument)
+	signers, messages := verifyTestDocument(t, other, signed)
+	if len(signers) != 0 {
+		t.Fatalf("signature of untrusted certificate is accepted")
+	}
+	expectMessage(t, messages, "is not trusted")
+}
+
+func TestVerifyTampered(t *testing.T) {
+	key := newTestSigningKey(t, "signer")
+	signed := signTestDocument(t, key, signatureTestDocument)
+	tampered := strings.Replace(signed, "100.00", "900.00", 1)
+	signers, messages := verifyTestDocument(t, key, tampered)
+	if len(signers) != 0 {
+		t.Fatalf("tampered document is accepted")
+	}
+	expectMessage(t, messages, "document is changed after signing")
+}
+
+func TestVerifyWrappedDocument(t *testing.T) {
+	key := newTestSigningKey(t, "signer")
+	signed := signTestDocument(t, key, signatureTestDocument)
+	original := signed[strings.Index(signed, "<rd:RdForm"):]
+	// signed RdForm is hidden inside forged one, signature is still enveloped by signed element
+	wrapped := `<rd:RdForm xmlns:rd="urn:schemas-rd-go-th:xml-services:common"><rd:Total>900.00</rd:Total>` + original + `</rd:RdForm>`
+	signers, messages := verifyTestDocument(t, key, wrapped)
+	if len(signers) != 0 {
+		t.Fatalf("wrapped document is accepted")
+	}
+	expectMessage(t, messages, "document is changed after signing")
+}
+
+func TestVerifyRootSignatureByID(t *testing.T) {
+	key := newTestSigningKey(t, "signer")
+	signature := signTestReference(t, key, signatureTestDocument, "tp")
+	document := strings.Replace(signatureTestDocument, "</rd:RdForm>", signature+"</rd:RdForm>", 1)
+	signers, messages := verifyTestDocument(t, key, document)
+	if len(signers) != 0 {
+		t.Fatalf("signature of RdForm covering only TaxPayer is accepted")
+	}
+	expectMessage(t, messages, `must sign whole document by reference URI=""`)
+}
+
+func TestVerifyWrappedReference(t *testing.T) {
+	key := newTestSigningKey(t, "signer")
+	document := strings.Replace(signatureTestDocument, "</rd:RdForm>", "<rd:Attachment></rd:Attachment></rd:RdForm>", 1)
+	signature := signTestReference(t, key, document, "tp")
+	document = strings.Replace(document, "<rd:Attachment>", "<rd:Attachment>"+signature, 1)
+
+	signers, messages := verifyTestDocument(t, key, document)
+	if len(signers) != 1 {
+		t.Fatalf("signature of element is rejected: %q", messages)
+	}
+	expectMessage(t, messages, "signature cover only TaxPayer at line 3")
+
+	// signed TaxPayer is moved into wrapper before forged one with same Id, so it is found first
+	signedTaxPayer := document[strings.Index(document, "<rd:TaxPayer") : strings.Index(document, "</rd:TaxPayer>")+len("</rd:TaxPayer>")]
+	wrapped := strings.Replace(document, "Somchai", "Mallory", 1)
+	wrapped = strings.Replace(wrapped, "<rd:TaxPayer", "<rd:Wrapper>"+signedTaxPayer+"</rd:Wrapper><rd:TaxPayer", 1)
+	signers, messages = verifyTestDocument(t, key, wrapped)
+	if len(signers) != 0 {
+		t.Fatalf("wrapped element is accepted")
+	}
+	expectMessage(t, messages, "is ambiguous, id is used by elements at line 3, 5")
+}
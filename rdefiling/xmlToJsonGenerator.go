@@ -0,0 +1,189 @@
+package rdefiling
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// resolveJSONKey find actual path of key in template the same way getJSONByKey does, page1 or page2 is inserted when key is found under it.
+// When key can't be found in template the key is returned as is.
+func resolveJSONKey(template interface{}, key string) []string {
+	tokens := strings.Split(key, ".")
+	var resolve func(interface{}, []string) []string
+	resolve = func(value interface{}, tokens []string) []string {
+		valueMap, ok := value.(map[string]interface{})
+		if !ok || len(tokens) == 0 {
+			return nil
+		}
+		if child, ok := valueMap[tokens[0]]; ok {
+			if len(tokens) == 1 {
+				return tokens
+			}
+			if rest := resolve(child, tokens[1:]); rest != nil {
+				return append([]string{tokens[0]}, rest...)
+			}
+		}
+		for _, page := range []string{"page1", "page2"} {
+			if rest := resolve(valueMap[page], tokens); rest != nil {
+				return append([]string{page}, rest...)
+			}
+		}
+		return nil
+	}
+	if resolved := resolve(template, tokens); resolved != nil {
+		return resolved
+	}
+	return tokens
+}
+
+func setJSONByKey(dst map[string]interface{}, tokens []string, value interface{}) {
+	for _, token := range tokens[:len(tokens)-1] {
+		child, ok := dst[token].(map[string]interface{})
+		if !ok {
+			child = map[string]interface{}{}
+			dst[token] = child
+		}
+		dst = child
+	}
+	dst[tokens[len(tokens)-1]] = value
+}
+
+// numberPattern is decimal number with optional exponent, as accepted by xs:decimal, xs:integer and xs:double.
+var numberPattern = regexp.MustCompile(`^([+-]?)([0-9]*)(?:\.([0-9]*))?(?:[eE]([+-]?[0-9]+))?$`)
+
+// jsonNumber rewrite number text of xml into JSON number grammar, such as +007.50 into 7.50. Other numbers
+// ParseFloat accept, such as hex float, are formatted from their value. NaN and Inf have no JSON form.
+func jsonNumber(data string) (json.Number, bool) {
+	if match := numberPattern.FindStringSubmatch(data); match != nil && match[2]+match[3] != "" {
+		number := strings.TrimPrefix(match[1], "+")
+		if integer := strings.TrimLeft(match[2], "0"); integer != "" {
+			number += integer
+		} else {
+			number += "0"
+		}
+		if match[3] != "" {
+			number += "." + match[3]
+		}
+		if match[4] != "" {
+			number += "e" + match[4]
+		}
+		return json.Number(number), true
+	}
+	value, err := strconv.ParseFloat(data, 64)
+	if err != nil || math.IsNaN(value) || math.IsInf(value, 0) {
+		return "", false
+	}
+	return json.Number(strconv.FormatFloat(value, 'g', -1, 64)), true
+}
+
+// jsonValueOfType convert xml text to json value according to spec type, empty number or boolean become null.
+func (s *Spec) jsonValueOfType(field *Field, typ string, data string) interface{} {
+	switch {
+	case typ == "Number" || strings.HasPrefix(typ, "Decimal"):
+		data = strings.TrimSpace(data)
+		if data == "" {
+			return nil
+		}
+		number, ok := jsonNumber(data)
+		if !ok {
+			s.errorf("xmlToJSON", field, "value %q is not finite number", data)
+			return data
+		}
+		return number
+	case typ == "Boolean":
+		switch strings.TrimSpace(data) {
+		case "true", "1":
+			return true
+		case "false", "0":
+			return false
+		case "":
+			return nil
+		}
+		s.errorf("xmlToJSON", field, "value %q is not boolean", data)
+		return data
+	}
+	return data
+}
+
+// XMLToJSON convert RdForm xml back to frontend json. Template is optional frontend json, it decide whether value is put under page1 or page2,
+// templateName is used in diagnostics. Fields with static override are left out, frontend never supply them.
+func (s *Spec) XMLToJSON(reader io.Reader, name string, templateReader io.Reader, templateName string, writer io.Writer) error {
+	jsonInput := s.Fields
+	root, err := parseXMLTree(reader)
+	if err != nil {
+		return fatalError("xmlToJSON", name, 0, "can't parse xml: %v", err)
+	}
+	var template interface{}
+	if templateReader != nil {
+		data, err := ioutil.ReadAll(templateReader)
+		if err != nil {
+			return fatalError("xmlToJSON", templateName, 0, "can't read template: %v", err)
+		}
+		if err := json.Unmarshal(data, &template); err != nil {
+			return fatalError("xmlToJSON", templateName, jsonErrorLine(data, err), "invalid json template: %v", err)
+		}
+	}
+
+	var transferValues func(map[string]interface{}, interface{}, *xmlNode, []Field, string, string)
+	transferValues = func(dst map[string]interface{}, template interface{}, context *xmlNode, datas []Field, fromKeyPrefix, toKeyPrefix string) {
+		for datasIndex := 0; datasIndex < len(datas); datasIndex++ {
+			data := datas[datasIndex]
+			if s.excluded(data.FromKey) || s.static(data.FromKey) {
+				continue
+			}
+			typ := s.jsonType(&data)
+			if s.ForceArray[data.FromKey] {
+				typ = "Array"
+			}
+			if typ == "Object" || data.ToKey == "" {
+				continue
+			}
+			if !strings.HasPrefix(data.FromKey, fromKeyPrefix) {
+				break
+			}
+			if !strings.HasPrefix(data.ToKey, toKeyPrefix) {
+				s.warnf("xmlToJSON", &data, "ToKey %s is not under array %s", data.ToKey, toKeyPrefix)
+				continue
+			}
+			toKey := resolveJSONKey(template, data.ToKey[len(toKeyPrefix):])
+			nodes := context.Find(data.FromKey)
+			if typ == "Array" {
+				newFromKeyPrefix, newToKeyPrefix := data.FromKey+".", data.ToKey+"."
+				end := datasIndex + 1
+				for end < len(datas) && strings.HasPrefix(datas[end].FromKey, newFromKeyPrefix) {
+					end++
+				}
+				if len(nodes) > 0 {
+					var elementTemplate interface{}
+					if templateArray, ok := getJSONByKey(template, strings.Join(toKey, ".")).([]interface{}); ok && len(templateArray) > 0 {
+						elementTemplate = templateArray[0]
+					}
+					array := []interface{}{}
+					for _, node := range nodes {
+						element := map[string]interface{}{}
+						transferValues(element, elementTemplate, node, datas[datasIndex+1:end], newFromKeyPrefix, newToKeyPrefix)
+						array = append(array, element)
+					}
+					setJSONByKey(dst, toKey, array)
+				}
+				datasIndex = end - 1
+			} else if len(nodes) > 0 {
+				if len(nodes) > 1 {
+					s.warnf("xmlToJSON", &data, "element occurs %d times but it is not array, use first one", len(nodes))
+				}
+				setJSONByKey(dst, toKey, s.jsonValueOfType(&data, typ, nodes[0].Data))
+			}
+		}
+	}
+	result := map[string]interface{}{}
+	transferValues(result, template, root, jsonInput, "", "")
+
+	encoder := json.NewEncoder(writer)
+	encoder.SetIndent("", "\t")
+	return encoder.Encode(result)
+}
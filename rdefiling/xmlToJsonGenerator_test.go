@@ -0,0 +1,101 @@
+package rdefiling
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestJSONNumber(t *testing.T) {
+	tests := []struct {
+		data string
+		want string
+		ok   bool
+	}{
+		{"144", "144", true},
+		{"+144", "144", true},
+		{"-144", "-144", true},
+		{"007", "7", true},
+		{"-007.50", "-7.50", true},
+		{"+0.5", "0.5", true},
+		{"000", "0", true},
+		{".5", "0.5", true},
+		{"5.", "5", true},
+		{"1e3", "1e3", true},
+		{"+1.5E+03", "1.5e+03", true},
+		{"0012e-2", "12e-2", true},
+		{"0x1p3", "8", true},
+		{"NaN", "", false},
+		{"Inf", "", false},
+		{"-Infinity", "", false},
+		{".", "", false},
+		{"1,000", "", false},
+		{"", "", false},
+	}
+	for _, test := range tests {
+		got, ok := jsonNumber(test.data)
+		if ok != test.ok || string(got) != test.want {
+			t.Errorf("jsonNumber(%q) = %q, %v, want %q, %v", test.data, got, ok, test.want, test.ok)
+		}
+	}
+}
+
+var xmlToJSONTestFields = []Field{
+	{FromKey: "TaxForm", Type: "Object"},
+	{FromKey: "TaxForm.Total", ToKey: "total", Type: "Decimal(15,2)"},
+	{FromKey: "TaxForm.Count", ToKey: "count", Type: "Number"},
+	{FromKey: "TaxForm.FilingNo", ToKey: "filingNo", Type: "Number"},
+	{FromKey: "TaxForm.Version", ToKey: "version", Type: "String"},
+}
+
+const xmlToJSONTestDocument = `<rd:RdForm xmlns:rd="urn:schemas-rd-go-th:xml-services:common"><rd:TaxForm>` +
+	`<rd:Total>+0144.50</rd:Total><rd:Count>1E2</rd:Count><rd:FilingNo>007</rd:FilingNo><rd:Version>3.0.0</rd:Version>` +
+	`</rd:TaxForm></rd:RdForm>`
+
+func TestXMLToJSONNumbers(t *testing.T) {
+	spec := &Spec{File: "spec.csv", Fields: xmlToJSONTestFields}
+	var output bytes.Buffer
+	if err := spec.XMLToJSON(strings.NewReader(xmlToJSONTestDocument), "test.xml", nil, "", &output); err != nil {
+		t.Fatal(err)
+	}
+	want := "{\n\t\"count\": 1e2,\n\t\"filingNo\": 7,\n\t\"total\": 144.50,\n\t\"version\": \"3.0.0\"\n}\n"
+	if output.String() != want {
+		t.Errorf("got %s, want %s", output.String(), want)
+	}
+	if len(spec.Diagnostics) != 0 {
+		t.Errorf("unexpected diagnostics %v", spec.Diagnostics)
+	}
+
+	spec = &Spec{File: "spec.csv", Fields: xmlToJSONTestFields}
+	output.Reset()
+	document := strings.Replace(xmlToJSONTestDocument, "+0144.50", "NaN", 1)
+	if err := spec.XMLToJSON(strings.NewReader(document), "test.xml", nil, "", &output); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(output.String(), `"total": "NaN"`) {
+		t.Errorf("NaN is not kept as text: %s", output.String())
+	}
+	if !spec.Diagnostics.HasError() || !strings.Contains(spec.Diagnostics[0].Message, `"NaN" is not finite number`) {
+		t.Errorf("diagnostics %v, want NaN error", spec.Diagnostics)
+	}
+}
+
+func TestXMLToJSONSkipStatic(t *testing.T) {
+	spec := &Spec{File: "spec.csv", Fields: xmlToJSONTestFields}
+	spec.ApplyOverrides(&Overrides{Static: map[string]interface{}{"TaxForm.Version": "3.0.0"}})
+	var output bytes.Buffer
+	if err := spec.XMLToJSON(strings.NewReader(xmlToJSONTestDocument), "test.xml", nil, "", &output); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(output.String(), "version") {
+		t.Errorf("static field is written into json: %s", output.String())
+	}
+}
+
+func TestXMLToJSONTemplateName(t *testing.T) {
+	spec := &Spec{File: "spec.csv", Fields: xmlToJSONTestFields}
+	err := spec.XMLToJSON(strings.NewReader(xmlToJSONTestDocument), "test.xml", strings.NewReader("{\n\"a\": }"), "template.json", &bytes.Buffer{})
+	if err == nil || !strings.HasPrefix(err.Error(), "template.json:2:") {
+		t.Errorf("error %v, want it located in template.json", err)
+	}
+}
@@ -0,0 +1,107 @@
+package rdefiling
+
+import (
+	"encoding/xml"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// xmlNode is element of parsed RdForm document, keep parent and position for validation and reporting.
+type xmlNode struct {
+	Name     string // local name without prefix
+	Space    string
+	FromKey  string // dot path below RdForm, same as Field.FromKey
+	Location string // xpath like location such as /RdForm/TaxForm[1]/Filing[1]
+	Line     int
+	Data     string
+	Parent   *xmlNode
+	Children []*xmlNode
+}
+
+// parseXMLTree read whole document into tree, root element is returned with empty FromKey.
+func parseXMLTree(reader io.Reader) (*xmlNode, error) {
+	decoder := xml.NewDecoder(reader)
+	var root *xmlNode
+	var current *xmlNode
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			line, _ := decoder.InputPos()
+			node := &xmlNode{Name: t.Name.Local, Space: t.Name.Space, Line: line, Parent: current}
+			if current == nil {
+				if root != nil {
+					return nil, &xml.SyntaxError{Msg: "multiple root elements", Line: line}
+				}
+				root = node
+				node.Location = "/" + node.Name
+			} else {
+				position := 1
+				for _, sibling := range current.Children {
+					if sibling.Name == node.Name {
+						position++
+					}
+				}
+				node.Location = current.Location + "/" + node.Name + "[" + strconv.Itoa(position) + "]"
+				if current.FromKey == "" {
+					node.FromKey = node.Name
+				} else {
+					node.FromKey = current.FromKey + "." + node.Name
+				}
+				current.Children = append(current.Children, node)
+			}
+			current = node
+		case xml.EndElement:
+			current = current.Parent
+		case xml.CharData:
+			if current != nil {
+				current.Data += string(t)
+			}
+		}
+	}
+	if root == nil {
+		return nil, io.ErrUnexpectedEOF
+	}
+	return root, nil
+}
+
+// Value is text content of element without surrounding space.
+func (node *xmlNode) Value() string {
+	return strings.TrimSpace(node.Data)
+}
+
+// Find return all descendant with FromKey equal to fromKey.
+func (node *xmlNode) Find(fromKey string) []*xmlNode {
+	var result []*xmlNode
+	var walk func(*xmlNode)
+	walk = func(n *xmlNode) {
+		if n.FromKey == fromKey {
+			result = append(result, n)
+			return
+		}
+		if n.FromKey != "" && !strings.HasPrefix(fromKey, n.FromKey+".") {
+			return
+		}
+		for _, child := range n.Children {
+			walk(child)
+		}
+	}
+	walk(node)
+	return result
+}
+
+// Relative find element of fromKey nearest to node, go up to common ancestor then down to fromKey.
+func (node *xmlNode) Relative(fromKey string) []*xmlNode {
+	ancestor := node
+	for ancestor.Parent != nil && !strings.HasPrefix(fromKey, ancestor.FromKey+".") {
+		ancestor = ancestor.Parent
+	}
+	return ancestor.Find(fromKey)
+}
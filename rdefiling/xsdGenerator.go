@@ -0,0 +1,389 @@
+package rdefiling
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"strconv"
+	"strings"
+	"tnd/pkg/encoding/strictxml"
+)
+
+// xsdFacet is constraining facet of simple type, facets are written in order of slice so that output is reproducible.
+type xsdFacet struct {
+	Name  string
+	Value string
+}
+
+// codeSimpleType add pattern and enumeration facets of field to facets of its type and name the new simple type after element,
+// such as BranchTypeCodeType or IdPatternType. Fields with same base and facets share one type.
+func codeSimpleType(field *Field, base string, facets []xsdFacet, used map[string]string) (string, []xsdFacet) {
+	suffix := "PatternType"
+	if len(field.Values) > 0 {
+		suffix = "CodeType"
+	}
+	facets = append([]xsdFacet{}, facets...)
+	if field.Pattern != "" {
+		facets = append(facets, xsdFacet{"pattern", xmlEscapeAttr(field.Pattern)})
+	}
+	for _, value := range field.Values {
+		facets = append(facets, xsdFacet{"enumeration", xmlEscapeAttr(value)})
+	}
+	signature := base
+	for _, facet := range facets {
+		signature += " " + facet.Name + "=" + facet.Value
+	}
+	var name string
+	tokens := strings.Split(field.FromKey, ".")
+	for depth := 1; ; depth++ {
+		name = strings.TrimSuffix(xsdTypeName(field.FromKey, depth), "Type") + suffix
+		if used[name] == "" || used[name] == signature || depth > len(tokens) {
+			break
+		}
+	}
+	qualified := name
+	for n := 2; used[name] != "" && used[name] != signature; n++ {
+		name = qualified + "_" + strconv.Itoa(n)
+	}
+	used[name] = signature
+	return name, facets
+}
+
+func xmlEscapeAttr(str string) string {
+	var buffer bytes.Buffer
+	xml.EscapeText(&buffer, []byte(str)) // can't fail, bytes.Buffer never return error
+	return buffer.String()
+}
+
+type xsdChild struct {
+	Name      string
+	Type      string // simple type name
+	Complex   int    `json:",omitempty"` // 1-based index of complex type
+	MinOccurs string
+	MaxOccurs string
+	// Fields are spec fields of this child in every path sharing the type, annotation is made of them
+	// so it is not part of type identity.
+	Fields []*Field `json:"-"`
+}
+
+type xsdComplexType struct {
+	Name   string
+	Paths  []string // paths of elements of this type in spec order, "" is RdForm
+	Childs []xsdChild
+	// Signature allow ds:Signature after childs
+	Signature bool
+}
+
+// xsdTypeName make type name from last depth elements of path, such as TaxPayerAddressType.
+func xsdTypeName(path string, depth int) string {
+	tokens := []string{"RdForm"}
+	if path != "" {
+		tokens = append(tokens, strings.Split(path, ".")...)
+	}
+	if depth > len(tokens) {
+		depth = len(tokens)
+	}
+	return strings.Join(tokens[len(tokens)-depth:], "") + "Type"
+}
+
+// nameComplexTypes name each complex type after element of its first path.
+// Types sharing a name are all qualified with their parent elements until names are unique,
+// so that name of a type does not depend on order of other types. Type which still clash get number suffix in spec order.
+func nameComplexTypes(types []*xsdComplexType, reserved map[string]bool) {
+	depth := make([]int, len(types))
+	for i := range depth {
+		depth[i] = 1
+	}
+	for {
+		clash := map[string][]int{}
+		for i, t := range types {
+			name := xsdTypeName(t.Paths[0], depth[i])
+			clash[name] = append(clash[name], i)
+		}
+		changed := false
+		for name, group := range clash {
+			if len(group) < 2 && !reserved[name] {
+				continue
+			}
+			for _, i := range group {
+				if xsdTypeName(types[i].Paths[0], depth[i]+1) != name {
+					depth[i]++
+					changed = true
+				}
+			}
+		}
+		if !changed {
+			break
+		}
+	}
+	used := map[string]bool{}
+	for name := range reserved {
+		used[name] = true
+	}
+	for i, t := range types {
+		name := xsdTypeName(t.Paths[0], depth[i])
+		t.Name = name
+		for n := 2; used[t.Name]; n++ {
+			t.Name = name + "_" + strconv.Itoa(n)
+		}
+		used[t.Name] = true
+	}
+}
+
+// XSDOptions control content of generated xsd.
+type XSDOptions struct {
+	// Annotate put description, Index and rule guideline codes of field into xs:annotation of its element.
+	// Element of shared complex type list Index of every field it stands for.
+	Annotate bool
+	// SignatureElements are FromKey of objects which may carry ds:Signature as their last child, "RdForm" is root.
+	// Nil means root only, empty slice means no signature at all.
+	SignatureElements []string
+	// SignatureSchemaLocation is schemaLocation of imported xmldsig-core schema, such as XMLDSigSchemaFile.
+	// Empty leaves import without location, validator must then know the schema by itself.
+	SignatureSchemaLocation string
+}
+
+// GenerateXSD write indented xsd of spec, with annotation, to writer.
+func (s *Spec) GenerateXSD(writer io.Writer) error {
+	return s.WriteXSD(writer, XSDOptions{Annotate: true})
+}
+
+// xsdAnnotation make xs:annotation of fields of one element, distinct descriptions are documentation,
+// Index and distinct rule codes are appinfo.
+func xsdAnnotation(fields []*Field) string {
+	escape := func(str string) string {
+		return strings.Replace(strings.Replace(xmlEscapeAttr(str), "&#xD;", "", -1), "&#xA;", "\n", -1)
+	}
+	var descriptions, indexes, rules []string
+	for _, field := range fields {
+		if description := strings.TrimSpace(field.Description); description != "" && !stringInSlice(description, descriptions) {
+			descriptions = append(descriptions, description)
+		}
+		indexes = append(indexes, field.Index)
+		for _, rule := range field.Rules {
+			if !stringInSlice(rule, rules) {
+				rules = append(rules, rule)
+			}
+		}
+	}
+	result := `<xs:annotation>`
+	for _, description := range descriptions {
+		result += `<xs:documentation xml:lang="th">` + escape(description) + `</xs:documentation>`
+	}
+	result += `<xs:appinfo>`
+	for _, index := range indexes {
+		result += `<rd:Index>` + escape(index) + `</rd:Index>`
+	}
+	for _, rule := range rules {
+		result += `<rd:Rule>` + escape(rule) + `</rd:Rule>`
+	}
+	return result + `</xs:appinfo></xs:annotation>`
+}
+
+// WriteXSD write indented xsd of spec to writer.
+func (s *Spec) WriteXSD(writer io.Writer, options XSDOptions) error {
+	jsonInput := s.Fields
+
+	output := &bytes.Buffer{}
+	output.WriteString(xml.Header)
+	output.WriteString(`<xs:schema
+	attributeFormDefault="unqualified" elementFormDefault="qualified"
+	targetNamespace="` + XMLNameSpace + `"
+	xmlns:xs="http://www.w3.org/2001/XMLSchema"
+	xmlns:rd="` + XMLNameSpace + `"
+	xmlns:ds="` + XMLDSigNameSpace + `">`)
+
+	usedSimpleType := map[string]bool{}
+	simpleTypeFacets := map[string]string{} // name of simple type with enumeration or pattern to its base and facets
+
+	output.WriteString(`<xs:import namespace="` + XMLDSigNameSpace + `"`)
+	if options.SignatureSchemaLocation != "" {
+		output.WriteString(` schemaLocation="` + xmlEscapeAttr(options.SignatureSchemaLocation) + `"`)
+	}
+	output.WriteString(` />`)
+	writeSimple := func(name string, xmlType string, facets []xsdFacet) {
+		if usedSimpleType[name] {
+			return
+		}
+		usedSimpleType[name] = true
+		output.WriteString(`<xs:simpleType name="` + name + `"><xs:restriction base="` + xmlType + `">`)
+		for _, facet := range facets {
+			output.WriteString(`<xs:` + facet.Name + ` value="` + facet.Value + `"/>`)
+		}
+		output.WriteString(`</xs:restriction></xs:simpleType>` + "\n")
+	}
+
+	parentChildMap := map[string][]string{}
+	ruleMap := map[string]Field{}
+	parentHasChildMap := map[string]map[string]bool{}
+	resolvedType := map[string]string{}
+	for _, rule := range jsonInput {
+		var xmlType string
+		setType := func(name string, _xmlType string, facets []xsdFacet) {
+			if len(rule.Values) > 0 || rule.Pattern != "" {
+				name, facets = codeSimpleType(&rule, _xmlType, facets, simpleTypeFacets)
+			}
+			xmlType = name
+			writeSimple(name, _xmlType, facets)
+		}
+		switch rule.Type {
+		case "Boolean":
+			setType("booleanType", "xs:boolean", nil)
+		case "Number":
+			typeName := "numberType"
+			var facets []xsdFacet
+			if rule.MaxLength != "" {
+				facets = append(facets, xsdFacet{"totalDigits", rule.MaxLength})
+				typeName += "Digits" + rule.MaxLength
+			}
+			setType(typeName, "xs:integer", facets)
+		case "String":
+			typeName := "stringType"
+			var facets []xsdFacet
+			if rule.MaxLength != "" {
+				facets = append(facets, xsdFacet{"maxLength", rule.MaxLength})
+				typeName += "Max" + rule.MaxLength
+			}
+			setType(typeName, "xs:string", facets)
+		case "Date":
+			setType("dateType", "xs:date", nil)
+		case "DateTime":
+			setType("dateTimeType", "xs:dateTime", nil)
+		case "Time":
+			setType("timeType", "xs:time", nil)
+		case "Year":
+			setType("yearType", "xs:gYear", nil)
+		case "Array":
+		case "Object":
+		default:
+			switch {
+			case strings.HasPrefix(rule.Type, "Decimal"):
+				precisionValue, scaleValue, ok := parseDecimalType(rule.Type)
+				if !ok {
+					s.errorf("xsd", &rule, "unknown decimal type %q", rule.Type)
+					continue
+				}
+				precision := strconv.Itoa(precisionValue)
+				scale := strconv.Itoa(scaleValue)
+				typeName := "decimalType" + precision + "fraction" + scale
+				setType(typeName, "xs:decimal", []xsdFacet{{"totalDigits", precision}, {"fractionDigits", scale}})
+			default:
+				s.errorf("xsd", &rule, "unknown type %q", rule.Type)
+				continue
+			}
+		}
+		tokens := strings.Split(rule.FromKey, ".")
+		for i := range tokens {
+			parentKey := strings.Join(tokens[:i], ".")
+			childKey := strings.Join(tokens[:i+1], ".")
+			if parentHasChildMap[parentKey] == nil {
+				parentHasChildMap[parentKey] = make(map[string]bool)
+			}
+			if parentHasChildMap[parentKey][childKey] {
+				continue
+			}
+			parentHasChildMap[parentKey][childKey] = true
+			parentChildMap[parentKey] = append(parentChildMap[parentKey], childKey)
+		}
+		ruleMap[rule.FromKey] = rule
+		if xmlType != "" {
+			resolvedType[rule.FromKey] = xmlType
+		}
+	}
+
+	signed := map[string]bool{"": options.SignatureElements == nil}
+	for _, key := range options.SignatureElements {
+		if key == "RdForm" {
+			key = ""
+		}
+		if _, ok := resolvedType[key]; ok || (key != "" && parentChildMap[key] == nil) {
+			s.warnf("xsd", nil, "signature element %s is not object of spec", key)
+			continue
+		}
+		signed[key] = true
+	}
+
+	var complexTypes []*xsdComplexType
+	usedComplexType := map[string]int{}
+	var printOutType func(string) (string, int)
+	printOutType = func(_typeKey string) (string, int) {
+		if str, ok := resolvedType[_typeKey]; ok {
+			return str, 0
+		}
+		var childs []xsdChild
+		for _, childKey := range parentChildMap[_typeKey] {
+			childType, complexIndex := printOutType(childKey)
+			tokens := strings.Split(childKey, ".")
+			childName := tokens[len(tokens)-1]
+			rule := ruleMap[childKey]
+			min, max, ok := parseMultiple(rule.Multiple)
+			if !ok {
+				s.errorf("xsd", &rule, "weird min max %q, expect [min…max]", rule.Multiple)
+				min, max = 1, 1
+			}
+			maxOccurs := "unbounded"
+			if max >= 0 {
+				maxOccurs = strconv.Itoa(max)
+			}
+			child := xsdChild{
+				Name:      childName,
+				Type:      childType,
+				Complex:   complexIndex,
+				MinOccurs: strconv.Itoa(min),
+				MaxOccurs: maxOccurs,
+			}
+			if options.Annotate && rule.FromKey != "" {
+				child.Fields = []*Field{&rule}
+			}
+			childs = append(childs, child)
+		}
+		typeValue, _ := json.MarshalIndent(childs, "", "") // can't fail, Fields are not marshalled
+		if _typeKey == "" {
+			typeValue = append([]byte("RdForm"), typeValue...) // root is never shared
+		} else if signed[_typeKey] {
+			typeValue = append([]byte("Signature"), typeValue...) // only signed elements share type with signature
+		}
+		if index, ok := usedComplexType[string(typeValue)]; ok {
+			complexTypes[index-1].Paths = append(complexTypes[index-1].Paths, _typeKey)
+			for i, child := range childs {
+				complexTypes[index-1].Childs[i].Fields = append(complexTypes[index-1].Childs[i].Fields, child.Fields...)
+			}
+			return "", index
+		}
+		complexTypes = append(complexTypes, &xsdComplexType{Paths: []string{_typeKey}, Childs: childs, Signature: signed[_typeKey]})
+		usedComplexType[string(typeValue)] = len(complexTypes)
+		return "", len(complexTypes)
+	}
+	_, rootType := printOutType("")
+	nameComplexTypes(complexTypes, usedSimpleType)
+
+	for _, t := range complexTypes {
+		output.WriteString(`<xs:complexType name="` + t.Name + `"><xs:sequence>`)
+		for _, child := range t.Childs {
+			childType := child.Type
+			if child.Complex > 0 {
+				childType = complexTypes[child.Complex-1].Name
+			}
+			output.WriteString(`<xs:element name="` + child.Name + `" type="rd:` + childType + `"`)
+			if child.MinOccurs != "1" {
+				output.WriteString(` minOccurs="` + child.MinOccurs + `"`)
+			}
+			if child.MaxOccurs != "1" {
+				output.WriteString(` maxOccurs="` + child.MaxOccurs + `"`)
+			}
+			if len(child.Fields) == 0 {
+				output.WriteString("/>")
+				continue
+			}
+			output.WriteString(">" + xsdAnnotation(child.Fields) + "</xs:element>")
+		}
+		if t.Signature {
+			output.WriteString(`<xs:element ref="ds:Signature" minOccurs="0" />`)
+		}
+		output.WriteString(`</xs:sequence></xs:complexType>`)
+	}
+	output.WriteString(`<xs:element name="RdForm" type="rd:` + complexTypes[rootType-1].Name + `"/>`)
+	output.WriteString(`</xs:schema>`)
+	return strictxml.FormatIndent(output, writer, "", "\t")
+}
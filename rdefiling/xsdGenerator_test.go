@@ -0,0 +1,105 @@
+package rdefiling
+
+import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite golden files in testdata")
+
+const xsdGoldenForm = "../specFile/pnd50_2563"
+
+// generateFormXSD generate xsd of form the way xsd command does.
+func generateFormXSD(t *testing.T, dir string) []byte {
+	t.Helper()
+	form, err := ReadForm(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spec, err := form.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if spec.Diagnostics.HasError() {
+		var text bytes.Buffer
+		spec.Diagnostics.WriteText(&text)
+		t.Fatalf("spec has errors:\n%s", text.String())
+	}
+	var output bytes.Buffer
+	if err := spec.WriteXSD(&output, XSDOptions{Annotate: true, SignatureElements: form.SignatureElements}); err != nil {
+		t.Fatal(err)
+	}
+	return output.Bytes()
+}
+
+func TestWriteXSDGolden(t *testing.T) {
+	golden := filepath.Join("testdata", "pnd50_2563.xsd")
+	got := generateFormXSD(t, xsdGoldenForm)
+	if *update {
+		if err := ioutil.WriteFile(golden, got, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := ioutil.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		line := bytes.Count(got[:commonPrefix(got, want)], []byte("\n")) + 1
+		t.Fatalf("xsd differ from %s at line %d, run go test -update to accept", golden, line)
+	}
+}
+
+func TestWriteXSDFacetOrder(t *testing.T) {
+	first := generateFormXSD(t, xsdGoldenForm)
+	// facets used to come from map, a few runs are enough to hit another iteration order
+	for i := 0; i < 10; i++ {
+		if got := generateFormXSD(t, xsdGoldenForm); !bytes.Equal(got, first) {
+			t.Fatalf("run %d differ from first run", i+2)
+		}
+	}
+	decimal := regexp.MustCompile(`(?s)<xs:restriction base="xs:decimal">(.*?)</xs:restriction>`)
+	facet := regexp.MustCompile(`<xs:(\w+) value=`)
+	matches := decimal.FindAllSubmatch(first, -1)
+	if len(matches) == 0 {
+		t.Fatal("no decimal type in xsd")
+	}
+	for _, match := range matches {
+		var names []string
+		for _, name := range facet.FindAllSubmatch(match[1], -1) {
+			names = append(names, string(name[1]))
+		}
+		if len(names) != 2 || names[0] != "totalDigits" || names[1] != "fractionDigits" {
+			t.Errorf("decimal facets %v, want [totalDigits fractionDigits]", names)
+		}
+	}
+}
+
+func TestCodeSimpleTypeFacetOrder(t *testing.T) {
+	field := &Field{FromKey: "TaxPayer.BranchType", Pattern: "[0-9]{5}", Values: []string{"H", "B", "A"}}
+	name, facets := codeSimpleType(field, "xs:string", []xsdFacet{{"maxLength", "5"}}, map[string]string{})
+	if name != "BranchTypeCodeType" {
+		t.Errorf("name %s, want BranchTypeCodeType", name)
+	}
+	want := []xsdFacet{{"maxLength", "5"}, {"pattern", "[0-9]{5}"}, {"enumeration", "H"}, {"enumeration", "B"}, {"enumeration", "A"}}
+	if len(facets) != len(want) {
+		t.Fatalf("facets %v, want %v", facets, want)
+	}
+	for i := range want {
+		if facets[i] != want[i] {
+			t.Fatalf("facets %v, want %v", facets, want)
+		}
+	}
+}
+
+func commonPrefix(a, b []byte) int {
+	i := 0
+	for i < len(a) && i < len(b) && a[i] == b[i] {
+		i++
+	}
+	return i
+}